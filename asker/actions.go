@@ -0,0 +1,248 @@
+package asker
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/jshirley/slack-ask/storage"
+
+	"github.com/nlopes/slack"
+)
+
+const (
+	ASK_CLAIM_ACTION    = "ask_claim"
+	ASK_RESOLVE_ACTION  = "ask_resolve"
+	ASK_ESCALATE_ACTION = "ask_escalate"
+
+	// ESCALATE_PRIORITY is the blocking answer whose priority mapping escalating uses
+	ESCALATE_PRIORITY = "911"
+)
+
+type slackBlock map[string]interface{}
+
+type BlockActionRequest struct {
+	Type        string     `json:"type"`
+	Token       string     `json:"token"`
+	Team        SlackTeam  `json:"team"`
+	Enterprise  SlackTuple `json:"enterprise"`
+	User        SlackTuple `json:"user"`
+	Channel     SlackTuple `json:"channel"`
+	ResponseURL string     `json:"response_url"`
+	Container   struct {
+		ChannelID string `json:"channel_id"`
+		MessageTS string `json:"message_ts"`
+	} `json:"container"`
+	Message struct {
+		Text        string             `json:"text"`
+		Attachments []slack.Attachment `json:"attachments"`
+	} `json:"message"`
+	Actions []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+}
+
+// askMessageBlocks renders the ask's message with who has claimed, escalated
+// or resolved it, and the buttons that still make sense
+func askMessageBlocks(text string, state *storage.AskMessage) []slackBlock {
+	blocks := []slackBlock{
+		slackBlock{"type": "section", "text": slackBlock{"type": "mrkdwn", "text": text}},
+	}
+
+	var status []string
+	if state.ClaimedBy != "" {
+		status = append(status, fmt.Sprintf(":raising_hand: Claimed by <@%s>", state.ClaimedBy))
+	}
+	if state.EscalatedBy != "" {
+		status = append(status, fmt.Sprintf(":rotating_light: Escalated by <@%s>", state.EscalatedBy))
+	}
+	if state.ResolvedBy != "" {
+		status = append(status, fmt.Sprintf(":white_check_mark: Resolved by <@%s>", state.ResolvedBy))
+	}
+	if len(status) > 0 {
+		blocks = append(blocks, slackBlock{
+			"type":     "context",
+			"elements": []slackBlock{slackBlock{"type": "mrkdwn", "text": strings.Join(status, "  ")}},
+		})
+	}
+
+	if state.ResolvedBy != "" {
+		return blocks
+	}
+
+	var buttons []slackBlock
+	if state.ClaimedBy == "" {
+		buttons = append(buttons, askButton(ASK_CLAIM_ACTION, "Claim", "primary"))
+	}
+	buttons = append(buttons, askButton(ASK_RESOLVE_ACTION, "Resolve", ""))
+	if state.EscalatedBy == "" {
+		buttons = append(buttons, askButton(ASK_ESCALATE_ACTION, "Escalate", "danger"))
+	}
+	return append(blocks, slackBlock{"type": "actions", "elements": buttons})
+}
+
+// askState is who acted on any of the ask's tickets
+func askState(messages []storage.AskMessage) *storage.AskMessage {
+	state := &storage.AskMessage{}
+	for _, message := range messages {
+		state.ClaimedBy = firstNonEmpty(state.ClaimedBy, message.ClaimedBy)
+		state.ResolvedBy = firstNonEmpty(state.ResolvedBy, message.ResolvedBy)
+		state.EscalatedBy = firstNonEmpty(state.EscalatedBy, message.EscalatedBy)
+	}
+	return state
+}
+
+func askButton(actionID string, label string, style string) slackBlock {
+	button := slackBlock{
+		"type":      "button",
+		"action_id": actionID,
+		"text":      slackBlock{"type": "plain_text", "text": label},
+	}
+	if style != "" {
+		button["style"] = style
+	}
+	return button
+}
+
+// hasAskActions is whether any of the tickets can be acted on from Slack
+func (a *Asker) hasAskActions(tickets []*Ticket) bool {
+	for _, ticket := range tickets {
+		backend, err := a.GetBackend(ticket.Backend)
+		if err != nil {
+			continue
+		}
+		// Claiming needs the clicker's account in the backend too
+		_, assigner := backend.(Assigner)
+		_, userResolver := backend.(UserResolver)
+		_, resolver := backend.(Resolver)
+		_, escalator := backend.(Escalator)
+		if (assigner && userResolver) || resolver || escalator {
+			return true
+		}
+	}
+	return false
+}
+
+// BlockActionHandler handles the buttons on an ask's message, acting on every
+// ticket the ask created and updating the message to match
+func (a *Asker) BlockActionHandler(w http.ResponseWriter, r *http.Request) {
+	request := BlockActionRequest{}
+	if err := json.Unmarshal([]byte(r.FormValue("payload")), &request); err != nil {
+		log.Printf("Unable to decode block action: %+v\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Bad request")
+		return
+	}
+
+	if !a.verifyToken(r, request.Token) {
+		log.Printf("Invalid token on block action, check configuration or ensure someone isn't sending you bogus data\n")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Bad request")
+		return
+	}
+
+	db := MgoDBFromRequest(r).ForWorkspace(request.Team.Id, request.Enterprise.Id)
+	messages, err := db.GetThreadAskMessages(request.Container.ChannelID, request.Container.MessageTS)
+	if err != nil || len(messages) == 0 {
+		log.Printf("Got a block action for a message we don't know about in %s: %v\n", request.Container.ChannelID, err)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// Slack only waits 3 seconds, and acting on every ticket can take longer
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "")
+
+	a.inBackground(request.Team.Id, request.Enterprise.Id, func(db storage.DataLayer) {
+		a.doAskActions(db, &request, messages)
+	})
+}
+
+// doAskActions acts on every ticket of the ask, then updates its message to match
+func (a *Asker) doAskActions(db storage.DataLayer, request *BlockActionRequest, messages []storage.AskMessage) {
+	token := a.teamToken(db, request.Team.Id)
+	var failures []string
+	for _, action := range request.Actions {
+		for i := range messages {
+			if err := a.doAskAction(db, token, action.ActionID, request, &messages[i]); err != nil {
+				log.Printf("Unable to %s %s: %v\n", action.ActionID, messages[i].Key, err)
+				failures = append(failures, fmt.Sprintf("%s: `%v`", messages[i].Key, err))
+				continue
+			}
+			if err := db.StoreAskMessage(&messages[i]); err != nil {
+				log.Printf("Unable to store the state of %s: %v\n", messages[i].Key, err)
+			}
+		}
+	}
+
+	err := chatUpdate(token, request.Container.ChannelID, request.Container.MessageTS, SlackResponseResult{
+		Text:        request.Message.Text,
+		Attachments: request.Message.Attachments,
+		Blocks:      askMessageBlocks(request.Message.Text, askState(messages)),
+	})
+	if err != nil {
+		log.Printf("Unable to update the ask message in %s: %v\n", request.Container.ChannelID, err)
+	}
+
+	if len(failures) > 0 {
+		respond(request.ResponseURL, SlackResponseResult{
+			ResponseType: "ephemeral",
+			Text:         fmt.Sprintf("Sorry! That didn't work for everything:\n%s", strings.Join(failures, "\n")),
+		})
+	}
+}
+
+func (a *Asker) doAskAction(db storage.DataLayer, token string, actionID string, request *BlockActionRequest, message *storage.AskMessage) error {
+	backend, err := a.GetBackend(message.Backend)
+	if err != nil {
+		return err
+	}
+
+	switch actionID {
+	case ASK_CLAIM_ACTION:
+		assigner, ok := backend.(Assigner)
+		resolver, canResolve := backend.(UserResolver)
+		if !ok || !canResolve {
+			return fmt.Errorf("%s tickets can't be claimed from Slack", message.Backend)
+		}
+		user, err := a.resolveUser(db, token, message.Backend, resolver, request.User.Id)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return fmt.Errorf("Unable to find your %s account", message.Backend)
+		}
+		if err := assigner.AssignTicket(message.Project, message.Key, user); err != nil {
+			return err
+		}
+		message.ClaimedBy = request.User.Id
+	case ASK_RESOLVE_ACTION:
+		resolver, ok := backend.(Resolver)
+		if !ok {
+			return fmt.Errorf("%s tickets can't be resolved from Slack", message.Backend)
+		}
+		if err := resolver.ResolveTicket(message.Project, message.Key); err != nil {
+			return err
+		}
+		message.ResolvedBy = request.User.Id
+	case ASK_ESCALATE_ACTION:
+		escalator, ok := backend.(Escalator)
+		if !ok {
+			return fmt.Errorf("%s tickets can't be escalated from Slack", message.Backend)
+		}
+		config, err := db.GetChannelConfig(message.ChannelID)
+		if err != nil {
+			return err
+		}
+		if err := escalator.EscalateTicket(message.Project, message.Key, priorityFor(config, ESCALATE_PRIORITY)); err != nil {
+			return err
+		}
+		message.EscalatedBy = request.User.Id
+	default:
+		return fmt.Errorf("Unknown action `%s`", actionID)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package asker
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jshirley/slack-ask/storage"
+)
+
+// resolvingBackend can resolve its tickets, failing for the keys in refuse
+type resolvingBackend struct {
+	fakeBackend
+	resolved []string
+	refuse   map[string]bool
+}
+
+func (r *resolvingBackend) ResolveTicket(project string, key string) error {
+	if r.refuse[key] {
+		return fmt.Errorf("Transition not allowed")
+	}
+	r.resolved = append(r.resolved, key)
+	return nil
+}
+
+// assigningBackend can assign tickets but not look up who to assign them to
+type assigningBackend struct {
+	fakeBackend
+}
+
+func (a *assigningBackend) AssignTicket(project string, key string, user *BackendUser) error {
+	return nil
+}
+
+// claimableBackend can also find the clicker's account
+type claimableBackend struct {
+	assigningBackend
+}
+
+func (c *claimableBackend) FindUserByEmail(email string) (*BackendUser, error) {
+	return &BackendUser{Name: email}, nil
+}
+
+// actionIDs is the buttons on the message, in order
+func actionIDs(blocks []slackBlock) []string {
+	var ids []string
+	for _, block := range blocks {
+		if block["type"] != "actions" {
+			continue
+		}
+		for _, button := range block["elements"].([]slackBlock) {
+			ids = append(ids, button["action_id"].(string))
+		}
+	}
+	return ids
+}
+
+func contextText(blocks []slackBlock) string {
+	for _, block := range blocks {
+		if block["type"] == "context" {
+			return block["elements"].([]slackBlock)[0]["text"].(string)
+		}
+	}
+	return ""
+}
+
+func TestAskMessageBlocks(t *testing.T) {
+	tests := []struct {
+		name    string
+		state   storage.AskMessage
+		buttons []string
+		status  string
+	}{
+		{"new", storage.AskMessage{}, []string{ASK_CLAIM_ACTION, ASK_RESOLVE_ACTION, ASK_ESCALATE_ACTION}, ""},
+		{"claimed", storage.AskMessage{ClaimedBy: "U2"}, []string{ASK_RESOLVE_ACTION, ASK_ESCALATE_ACTION}, ":raising_hand: Claimed by <@U2>"},
+		{"escalated", storage.AskMessage{EscalatedBy: "U3"}, []string{ASK_CLAIM_ACTION, ASK_RESOLVE_ACTION}, ":rotating_light: Escalated by <@U3>"},
+		{"resolved", storage.AskMessage{ClaimedBy: "U2", ResolvedBy: "U2"}, nil, ":raising_hand: Claimed by <@U2>  :white_check_mark: Resolved by <@U2>"},
+	}
+	for _, test := range tests {
+		blocks := askMessageBlocks("Created PROJ-1", &test.state)
+		if blocks[0]["text"].(slackBlock)["text"] != "Created PROJ-1" {
+			t.Errorf("%s: the message starts with %+v", test.name, blocks[0])
+		}
+		if got := actionIDs(blocks); !reflect.DeepEqual(got, test.buttons) {
+			t.Errorf("%s: buttons are %v, want %v", test.name, got, test.buttons)
+		}
+		if got := contextText(blocks); got != test.status {
+			t.Errorf("%s: status is %q, want %q", test.name, got, test.status)
+		}
+	}
+}
+
+func TestAskState(t *testing.T) {
+	state := askState([]storage.AskMessage{
+		{Key: "PROJ-1", ClaimedBy: "U2"},
+		{Key: "org/repo#1", ClaimedBy: "U4", ResolvedBy: "U3"},
+	})
+	want := &storage.AskMessage{ClaimedBy: "U2", ResolvedBy: "U3"}
+	if !reflect.DeepEqual(state, want) {
+		t.Errorf("askState = %+v, want %+v", state, want)
+	}
+}
+
+func TestHasAskActions(t *testing.T) {
+	ask := &Asker{}
+	ask.RegisterBackend("plain", &fakeBackend{})
+	ask.RegisterBackend("assigning", &assigningBackend{})
+	ask.RegisterBackend("claimable", &claimableBackend{})
+	ask.RegisterBackend("resolving", &resolvingBackend{})
+
+	tests := []struct {
+		backends []string
+		want     bool
+	}{
+		{nil, false},
+		{[]string{"plain"}, false},
+		{[]string{"assigning"}, false},
+		{[]string{"missing"}, false},
+		{[]string{"claimable"}, true},
+		{[]string{"resolving"}, true},
+		{[]string{"plain", "missing", "resolving"}, true},
+	}
+	for _, test := range tests {
+		var tickets []*Ticket
+		for _, backend := range test.backends {
+			tickets = append(tickets, &Ticket{Backend: backend, Key: "KEY-1"})
+		}
+		if got := ask.hasAskActions(tickets); got != test.want {
+			t.Errorf("hasAskActions(%v) = %v, want %v", test.backends, got, test.want)
+		}
+	}
+}
+
+func TestDoAskAction(t *testing.T) {
+	resolving := &resolvingBackend{refuse: map[string]bool{"PROJ-2": true}}
+	ask := &Asker{}
+	ask.RegisterBackend("plain", &fakeBackend{})
+	ask.RegisterBackend("resolving", resolving)
+
+	request := &BlockActionRequest{}
+	request.User.Id = "U2"
+
+	// Resolving records who did it
+	message := &storage.AskMessage{Backend: "resolving", Project: "PROJ", Key: "PROJ-1"}
+	if err := ask.doAskAction(nil, "", ASK_RESOLVE_ACTION, request, message); err != nil {
+		t.Fatal(err)
+	}
+	if message.ResolvedBy != "U2" || !reflect.DeepEqual(resolving.resolved, []string{"PROJ-1"}) {
+		t.Errorf("Resolving left %+v and resolved %v", message, resolving.resolved)
+	}
+
+	// Nothing is recorded when the backend can't, or won't
+	tests := []struct {
+		message  storage.AskMessage
+		actionID string
+		err      string
+	}{
+		{storage.AskMessage{Backend: "resolving", Key: "PROJ-2"}, ASK_RESOLVE_ACTION, "Transition not allowed"},
+		{storage.AskMessage{Backend: "plain", Key: "PROJ-1"}, ASK_RESOLVE_ACTION, "can't be resolved from Slack"},
+		{storage.AskMessage{Backend: "plain", Key: "PROJ-1"}, ASK_CLAIM_ACTION, "can't be claimed from Slack"},
+		{storage.AskMessage{Backend: "resolving", Key: "PROJ-1"}, ASK_ESCALATE_ACTION, "can't be escalated from Slack"},
+		{storage.AskMessage{Backend: "resolving", Key: "PROJ-1"}, "ask_delete", "Unknown action"},
+		{storage.AskMessage{Backend: "missing", Key: "PROJ-1"}, ASK_RESOLVE_ACTION, "is not configured"},
+	}
+	for _, test := range tests {
+		err := ask.doAskAction(nil, "", test.actionID, request, &test.message)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s on %s returned %v, want an error containing %q", test.actionID, test.message.Backend, err, test.err)
+		}
+		if test.message.ClaimedBy != "" || test.message.ResolvedBy != "" || test.message.EscalatedBy != "" {
+			t.Errorf("%s on %s recorded %+v", test.actionID, test.message.Backend, test.message)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package asker
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/jshirley/slack-ask/storage"
+)
+
+// Assignee is who an ask is assigned to. Name is the user in the ticket
+// backend, SlackID is who gets mentioned in the channel.
+type Assignee struct {
+	Name    string `json:"assignee"`
+	SlackID string `json:"slack_user_id"`
+}
+
+type assignPayload struct {
+	Command    *storage.SlashCommand `json:"command"`
+	Submission map[string]string     `json:"submission"`
+	Targets    []storage.LinkTarget  `json:"targets"`
+}
+
+// findAssignee asks the channel's AssignEndpoint who should take the ask. A
+// channel without an endpoint uses its rotation, if it has one.
+func (a *Asker) findAssignee(db storage.DataLayer, originalAsk *storage.SlashCommand, request *InteractiveRequest) (*Assignee, error) {
+	config := originalAsk.Config
+	if config.AssignEndpoint == "" {
+		return a.rotationAssignee(db, originalAsk.ChannelID)
+	}
+
+	assignee := Assignee{}
+	err := postSigned(config.AssignEndpoint, a.WebhookSecret, a.WebhookHosts, assignPayload{
+		Command:    originalAsk,
+		Submission: request.Submission,
+		Targets:    config.LinkTargets(),
+	}, &assignee)
+	if err != nil {
+		return nil, err
+	}
+	if assignee.Name == "" && assignee.SlackID == "" {
+		return nil, nil
+	}
+
+	log.Printf("Assigning ask in %s to %s (<@%s>)\n", originalAsk.ChannelID, assignee.Name, assignee.SlackID)
+	return &assignee, nil
+}
+
+func describeAssignment(config *storage.ChannelConfig) string {
+	if config.AssignEndpoint == "" {
+		return "the channel's rotation (see `/ask rotation`)"
+	}
+	return config.AssignEndpoint
+}
+
+func (assignee *Assignee) Mention() string {
+	if assignee.SlackID != "" {
+		return fmt.Sprintf("<@%s>", assignee.SlackID)
+	}
+	// Whatever the endpoint said, it can't mention the whole channel
+	return escapeSlack(assignee.Name)
+}
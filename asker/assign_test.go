@@ -0,0 +1,20 @@
+package asker
+
+import "testing"
+
+func TestAssigneeMention(t *testing.T) {
+	tests := []struct {
+		assignee Assignee
+		want     string
+	}{
+		{Assignee{Name: "jane", SlackID: "U123"}, "<@U123>"},
+		{Assignee{Name: "jane"}, "jane"},
+		{Assignee{Name: "<!channel>"}, "&lt;!channel&gt;"},
+		{Assignee{Name: "Q&A <@U999>"}, "Q&amp;A &lt;@U999&gt;"},
+	}
+	for _, test := range tests {
+		if got := test.assignee.Mention(); got != test.want {
+			t.Errorf("Mention of %+v = %q, want %q", test.assignee, got, test.want)
+		}
+	}
+}
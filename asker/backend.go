@@ -0,0 +1,311 @@
+package asker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/jshirley/slack-ask/storage"
+)
+
+// DEFAULT_BACKEND is used for channels linked before backends were selectable
+const DEFAULT_BACKEND = "jira"
+
+type Ticket struct {
+	Key     string
+	URL     string
+	Summary string
+	Status  string
+
+	// Skipped is what the backend refused and the ticket was created without
+	Skipped []string
+
+	// Which link target the ticket was created in
+	Backend string
+	Project string
+}
+
+// TicketBackend is anything that can receive an ask and hand back a ticket
+type TicketBackend interface {
+	CreateTicket(request *TicketRequest) (*Ticket, error)
+	GetTicket(project string, key string) (*Ticket, error)
+	GetTicketURL(project string, key string) string
+	GetComponents(project string) ([]string, error)
+}
+
+// IssueTyper is implemented by backends where tickets have a type, like JIRA
+type IssueTyper interface {
+	GetIssueTypes(project string) ([]string, error)
+}
+
+// ProjectValidator is implemented by backends that can check a project is
+// usable before a channel is linked to it
+type ProjectValidator interface {
+	ValidateProject(project string) error
+}
+
+// ProjectLister is implemented by backends that can suggest projects when a
+// link fails validation
+type ProjectLister interface {
+	ListProjects() ([]string, error)
+}
+
+// RemoteLinker is implemented by backends that can link a ticket to the Slack message it came from
+type RemoteLinker interface {
+	AddRemoteLink(ticket *Ticket, url string, title string) error
+}
+
+// Commenter is implemented by backends that can take comments on a ticket
+type Commenter interface {
+	AddComment(project string, key string, body string) error
+}
+
+// Assigner, Resolver and Escalator are implemented by backends that can act on
+// the buttons of the ask's message
+type Assigner interface {
+	AssignTicket(project string, key string, user *BackendUser) error
+}
+
+type Resolver interface {
+	ResolveTicket(project string, key string) error
+}
+
+type Escalator interface {
+	EscalateTicket(project string, key string, priority storage.PriorityMapping) error
+}
+
+func (a *Asker) RegisterBackend(name string, backend TicketBackend) {
+	if a.backends == nil {
+		a.backends = map[string]TicketBackend{}
+	}
+	a.backends[name] = backend
+}
+
+func (a *Asker) GetBackend(name string) (TicketBackend, error) {
+	if name == "" {
+		name = DEFAULT_BACKEND
+	}
+
+	backend, ok := a.backends[name]
+	if !ok {
+		return nil, fmt.Errorf("The `%s` backend is not configured", name)
+	}
+	return backend, nil
+}
+
+func (a *Asker) BackendForChannel(config *storage.ChannelConfig) (TicketBackend, error) {
+	return a.GetBackend(config.Backend)
+}
+
+// parseLinkTarget splits `backend:project` into its parts, falling back to the
+// default backend for a bare project key like `PROJ`.
+func (a *Asker) parseLinkTarget(target string) (storage.LinkTarget, error) {
+	link := storage.LinkTarget{Backend: DEFAULT_BACKEND, Project: target}
+	if i := strings.Index(target, ":"); i > 0 {
+		link.Backend, link.Project = target[:i], target[i+1:]
+	}
+
+	// Slack wraps anything that looks like a link in angle brackets, sometimes with a |label
+	link.Project = strings.TrimSuffix(strings.TrimPrefix(link.Project, "<"), ">")
+	if i := strings.Index(link.Project, "|"); i >= 0 {
+		link.Project = link.Project[:i]
+	}
+	if link.Project == "" {
+		return link, fmt.Errorf("No project given to link to")
+	}
+	if _, err := a.GetBackend(link.Backend); err != nil {
+		return link, err
+	}
+	return link, nil
+}
+
+// findIssueType checks the issue type against what the channel's first project
+// with issue types allows, returning the name as the backend spells it.
+func (a *Asker) findIssueType(config *storage.ChannelConfig, issueType string) (string, error) {
+	for _, target := range config.LinkTargets() {
+		backend, err := a.GetBackend(target.Backend)
+		if err != nil {
+			continue
+		}
+		typer, ok := backend.(IssueTyper)
+		if !ok {
+			continue
+		}
+
+		issueTypes, err := typer.GetIssueTypes(target.Project)
+		if err != nil {
+			return "", err
+		}
+		for _, name := range issueTypes {
+			if strings.ToLower(name) == strings.ToLower(issueType) {
+				return name, nil
+			}
+		}
+		return "", fmt.Errorf("`%s` is not an issue type in %s, try one of: %s", issueType, target.Project, strings.Join(issueTypes, ", "))
+	}
+	return "", fmt.Errorf("None of %s have issue types", describeTargets(config.LinkTargets()))
+}
+
+// validateTarget checks with the backend that asks can be created in the
+// project, suggesting similarly named projects when they can't
+func (a *Asker) validateTarget(target storage.LinkTarget) error {
+	backend, err := a.GetBackend(target.Backend)
+	if err != nil {
+		return err
+	}
+
+	validator, ok := backend.(ProjectValidator)
+	if !ok {
+		return nil
+	}
+	err = validator.ValidateProject(target.Project)
+	if err == nil {
+		return nil
+	}
+
+	if lister, ok := backend.(ProjectLister); ok {
+		if projects, listErr := lister.ListProjects(); listErr == nil {
+			if suggestions := suggestProjects(target.Project, projects); len(suggestions) > 0 {
+				return fmt.Errorf("%v (did you mean %s?)", err, strings.Join(suggestions, ", "))
+			}
+		}
+	}
+	return err
+}
+
+// suggestProjects returns up to 5 projects that look like a typo of project
+func suggestProjects(project string, projects []string) []string {
+	project = strings.ToUpper(project)
+	maxDistance := 2
+	if len(project) <= 3 {
+		maxDistance = 1
+	}
+
+	var suggestions []string
+	for distance := 0; distance <= maxDistance; distance++ {
+		for _, candidate := range projects {
+			upper := strings.ToUpper(candidate)
+			candidateDistance := levenshtein(project, upper)
+			if strings.HasPrefix(upper, project) && candidateDistance > maxDistance {
+				candidateDistance = maxDistance
+			}
+			if candidateDistance == distance {
+				suggestions = append(suggestions, candidate)
+			}
+		}
+	}
+
+	if len(suggestions) > 5 {
+		suggestions = suggestions[:5]
+	}
+	return suggestions
+}
+
+func levenshtein(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = previous[j-1] + cost
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+func describeTargets(targets []storage.LinkTarget) string {
+	var described []string
+	for _, target := range targets {
+		backend := target.Backend
+		if backend == "" {
+			backend = DEFAULT_BACKEND
+		}
+		described = append(described, fmt.Sprintf("%s project %s", backend, target.Project))
+	}
+	return strings.Join(described, ", then ")
+}
+
+// ticketLink formats a ticket for Slack, linking it when the backend gave a URL.
+// Webhooks don't have to give the ticket a key.
+func ticketLink(ticket *Ticket) string {
+	// Webhooks say what the key and URL are, so neither can mention anyone
+	label := escapeSlack(firstNonEmpty(ticket.Key, "received"))
+	if ticket.URL == "" {
+		return label
+	}
+	return fmt.Sprintf("<%s|%s>", strings.Replace(escapeSlack(ticket.URL), "|", "%7C", -1), label)
+}
+
+// httpError is a response outside 2xx, so callers can tell a 404 from anything else
+type httpError struct {
+	Method     string
+	URL        string
+	Status     string
+	StatusCode int
+	Body       string
+}
+
+func (e *httpError) Error() string {
+	return fmt.Sprintf("%s %s failed with %s: %s", e.Method, e.URL, e.Status, e.Body)
+}
+
+// isNotFound is whether the error is a 404 from doJSONRequest
+func isNotFound(err error) bool {
+	httpErr, ok := err.(*httpError)
+	return ok && httpErr.StatusCode == http.StatusNotFound
+}
+
+// doJSONRequest sends body as JSON and decodes the response into out, for the
+// backends that talk plain REST instead of through a client library.
+func doJSONRequest(method string, endpoint string, headers map[string]string, body interface{}, out interface{}) error {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, endpoint, &reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := getHTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		bodyBytes, _ := ioutil.ReadAll(resp.Body)
+		return &httpError{Method: method, URL: endpoint, Status: resp.Status, StatusCode: resp.StatusCode, Body: string(bodyBytes)}
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
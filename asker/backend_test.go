@@ -0,0 +1,40 @@
+package asker
+
+import (
+	"testing"
+
+	"github.com/jshirley/slack-ask/storage"
+)
+
+func TestParseLinkTarget(t *testing.T) {
+	a := &Asker{}
+	a.RegisterBackend("jira", &JiraClient{})
+	a.RegisterBackend("github", &GithubClient{})
+	a.RegisterBackend("webhook", &WebhookClient{secret: "secret"})
+
+	tests := []struct {
+		target string
+		want   storage.LinkTarget
+		err    bool
+	}{
+		{"PROJ", storage.LinkTarget{Backend: "jira", Project: "PROJ"}, false},
+		{"jira:PROJ", storage.LinkTarget{Backend: "jira", Project: "PROJ"}, false},
+		{"github:org/repo", storage.LinkTarget{Backend: "github", Project: "org/repo"}, false},
+		{"webhook:<https://tools.example.com/asks>", storage.LinkTarget{Backend: "webhook", Project: "https://tools.example.com/asks"}, false},
+		{"webhook:<https://tools.example.com/asks|tools.example.com/asks>", storage.LinkTarget{Backend: "webhook", Project: "https://tools.example.com/asks"}, false},
+		{"github:", storage.LinkTarget{}, true},
+		{"gitlab:group/project", storage.LinkTarget{}, true},
+	}
+	for _, test := range tests {
+		got, err := a.parseLinkTarget(test.target)
+		if test.err {
+			if err == nil {
+				t.Errorf("parseLinkTarget(%q) = %+v, want an error", test.target, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("parseLinkTarget(%q) = %+v, %v, want %+v", test.target, got, err, test.want)
+		}
+	}
+}
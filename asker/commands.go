@@ -1,13 +1,13 @@
 package asker
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/jshirley/slack-ask/storage"
@@ -27,12 +27,28 @@ type SlackTuple struct {
 }
 
 type TicketRequest struct {
-	Username    string
-	ProjectKey  string
-	Summary     string
-	Description string
-	Priority    string
-	Components  []string
+	Username    string   `json:"username"`
+	UserID      string   `json:"user_id"`
+	Assignee    string   `json:"assignee,omitempty"`
+	ChannelID   string   `json:"channel_id"`
+	ProjectKey  string   `json:"project"`
+	Summary     string   `json:"summary"`
+	Description string   `json:"description"`
+	IssueType   string   `json:"issue_type,omitempty"`
+	Priority    string   `json:"priority,omitempty"`
+	Components  []string `json:"components"`
+	Labels      []string `json:"labels,omitempty"`
+
+	// Extra JIRA fields from the dialog, by field ID
+	Fields map[string]interface{} `json:"fields,omitempty"`
+
+	// Reporter and AssigneeUser are the backend's users for the Slack users, when they could be found
+	Reporter     *BackendUser `json:"-"`
+	AssigneeUser *BackendUser `json:"-"`
+
+	// The original ask, for backends that want more than the ticket fields
+	Command    *storage.SlashCommand `json:"-"`
+	Submission map[string]string     `json:"-"`
 }
 
 type InteractiveRequest struct {
@@ -40,6 +56,7 @@ type InteractiveRequest struct {
 	Submission map[string]string `json:"submission"`
 	CallbackID string            `json:"callback_id"`
 	Team       SlackTeam         `json:"team"`
+	Enterprise SlackTuple        `json:"enterprise"`
 	User       SlackTuple        `json:"user"`
 	Channel    SlackTuple        `json:"channel"`
 	Timestamp  string            `json:"action_ts"`
@@ -62,7 +79,7 @@ func (a *Asker) parseSlashCommand(r *http.Request) (*storage.SlashCommand, error
 		return nil, err
 	}
 
-	if command.Token != a.Token {
+	if !a.verifyToken(r, command.Token) {
 		return nil, fmt.Errorf("Invalid token, check configuration or ensure someone isn't sending you bogus data")
 	}
 
@@ -80,34 +97,53 @@ func (a *Asker) parseInteractiveRequest(r *http.Request) (*InteractiveRequest, e
 	if err := json.Unmarshal([]byte(r.FormValue("payload")), request); err != nil {
 		return nil, err
 	}
+	if request.Type == "view_submission" {
+		token := a.teamToken(MgoDBFromRequest(r), request.Team.Id)
+		if err := a.parseViewSubmission(token, []byte(r.FormValue("payload")), request); err != nil {
+			return nil, err
+		}
+	}
 
-	if request.Token != a.Token {
+	if !a.verifyToken(r, request.Token) {
 		return nil, fmt.Errorf("Invalid token on dialog submission, check configuration or ensure someone isn't sending you bogus data")
 	}
 	return request, nil
 }
 
-func (a *Asker) OpenDialog(callback string, config *storage.ChannelConfig, triggerId string) error {
+// OpenDialog opens the ask form as a modal, with values prefilling elements by name
+func (a *Asker) OpenDialog(token string, callback string, config *storage.ChannelConfig, triggerId string, values map[string]string) error {
 	dialog := a.GetDialog(callback)
+	dialog.Elements = append([]DialogElement(nil), dialog.Elements...)
+	for i, element := range dialog.Elements {
+		if value, ok := values[element.Name]; ok {
+			dialog.Elements[i].Value = value
+		}
+	}
 
-	dialogJson, err := json.Marshal(dialog)
+	return a.openView(token, triggerId, modalView(dialog))
+}
+
+func (a *Asker) openView(token string, triggerId string, view slackBlock) error {
+	viewJson, err := json.Marshal(view)
 	if err != nil {
-		log.Printf("Error encoding Dialog JSON: %+v\n", err)
+		log.Printf("Error encoding view JSON: %+v\n", err)
 		return err
 	}
 
-	values := url.Values{
-		"token":      {a.OAuth},
+	params := url.Values{
+		"token":      {token},
 		"trigger_id": {triggerId},
-		"dialog":     {string(dialogJson)},
+		"view":       {string(viewJson)},
 	}
 
 	response := slack.SlackResponse{}
-	err = post(context.Background(), "dialog.open", values, response, true)
+	err = post(context.Background(), "views.open", params, &response, true)
 	if err != nil {
 		return err
 	}
-	log.Printf("Got a response from Slack, are things ok? %+v\n", response.Ok)
+	if !response.Ok {
+		return fmt.Errorf("Slack refused to open the form: %s", response.Error)
+	}
 	return nil
 }
 
@@ -115,52 +151,201 @@ type SlackResponseResult struct {
 	ResponseType string             `json:"response_type"`
 	Text         string             `json:"text"`
 	Attachments  []slack.Attachment `json:"attachments"`
+	Blocks       []slackBlock       `json:"blocks,omitempty"`
 }
 
-func (a *Asker) PostAskResult(originalAsk *storage.SlashCommand, request *InteractiveRequest) error {
-	ticket := TicketRequest{
-		Username:    originalAsk.UserName,
-		Summary:     request.Submission["summary"],
-		Description: request.Submission["description"],
-		ProjectKey:  originalAsk.Config.Project,
-		Components:  originalAsk.Config.Components,
+// createTickets creates the ask in every target the channel is linked to, in
+// order, returning the tickets that were created and why the others failed.
+func (a *Asker) createTickets(db storage.DataLayer, originalAsk *storage.SlashCommand, request *InteractiveRequest, assignee *Assignee) ([]*Ticket, []string) {
+	var tickets []*Ticket
+	var failures []string
+
+	priority := priorityFor(originalAsk.Config, request.Submission["blocking"])
+	token := a.teamToken(db, originalAsk.TeamID)
+	summary := MrkdwnToPlain(a.labelMentions(token, request.Submission["summary"]))
+	description := a.labelMentions(token, request.Submission["description"])
+	askedIn := slackContext(originalAsk)
+
+	for _, target := range originalAsk.Config.LinkTargets() {
+		if target.Backend == "" {
+			// Linked before backends were selectable, stored so the JIRA webhook finds it
+			target.Backend = DEFAULT_BACKEND
+		}
+		ticket := TicketRequest{
+			Username:    originalAsk.UserName,
+			UserID:      originalAsk.UserID,
+			ChannelID:   originalAsk.ChannelID,
+			Summary:     summary,
+			Description: description,
+			ProjectKey:  target.Project,
+			Components:  originalAsk.Config.Components,
+			IssueType:   originalAsk.Config.IssueType,
+			Priority:    priority.Priority,
+			Labels:      priority.Labels,
+			Command:     originalAsk,
+			Submission:  request.Submission,
+		}
+		if assignee != nil {
+			ticket.Assignee = assignee.Name
+		}
+		if err := a.applyFieldMappings(&ticket, request.Submission); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: `%v`", describeTargets([]storage.LinkTarget{target}), err))
+			continue
+		}
+		ticket.Description = strings.TrimSpace(ticket.Description + "\n\n" + askedIn)
+		log.Printf("Creating a %s ticket in %s by %s\n", target.Backend, ticket.ProjectKey, ticket.Username)
+
+		var issue *Ticket
+		backend, err := a.GetBackend(target.Backend)
+		if resolver, ok := backend.(UserResolver); ok && err == nil {
+			if ticket.Reporter, err = a.resolveUser(db, token, target.Backend, resolver, originalAsk.UserID); err != nil {
+				log.Printf("Unable to find the %s user for %s, using the default reporter: %v\n", target.Backend, originalAsk.UserID, err)
+				err = nil
+			}
+			if assignee != nil && assignee.SlackID != "" {
+				if ticket.AssigneeUser, err = a.resolveUser(db, token, target.Backend, resolver, assignee.SlackID); err != nil {
+					log.Printf("Unable to find the %s user for %s, assigning to %s: %v\n", target.Backend, assignee.SlackID, ticket.Assignee, err)
+					err = nil
+				}
+			}
+		}
+		if err == nil {
+			issue, err = backend.CreateTicket(&ticket)
+		}
+		if err != nil {
+			log.Printf("Failed creating ticket in %s: %v\n", describeTargets([]storage.LinkTarget{target}), err)
+			failures = append(failures, fmt.Sprintf("%s: `%v`", describeTargets([]storage.LinkTarget{target}), err))
+			continue
+		}
+
+		issue.Backend, issue.Project = target.Backend, target.Project
+		tickets = append(tickets, issue)
 	}
-	log.Printf("Creating a JIRA ticket in %s by %s\n", ticket.ProjectKey, ticket.Username)
-	issue, err := a.Jira.CreateIssue(&ticket)
 
-	response := SlackResponseResult{}
+	return tickets, failures
+}
+
+func (a *Asker) PostAskResult(db storage.DataLayer, originalAsk *storage.SlashCommand, request *InteractiveRequest) error {
+	assignee, err := a.findAssignee(db, originalAsk, request)
 	if err != nil {
-		response = SlackResponseResult{
-			Text: fmt.Sprintf("Sorry! We failed to create an issue for that... please try again, and if it is helpful the error is `%v`", err),
+		log.Printf("Unable to find an assignee, leaving the ask unassigned: %v\n", err)
+	}
+
+	tickets, failures := a.createTickets(db, originalAsk, request, assignee)
+	token := a.teamToken(db, originalAsk.TeamID)
+
+	if len(tickets) == 0 {
+		return a.respondToAsker(token, originalAsk, SlackResponseResult{
+			Text: fmt.Sprintf("Sorry! We failed to create an issue for that... please try again, and if it is helpful the error is %s", strings.Join(failures, ", ")),
+		})
+	}
+
+	var links []string
+	for _, issue := range tickets {
+		links = append(links, ticketLink(issue))
+	}
+	response := SlackResponseResult{
+		ResponseType: "in_channel",
+		Text:         fmt.Sprintf("<@%s> is `/ask`ing \"%s\" (%s)", originalAsk.UserID, request.Submission["summary"], strings.Join(links, ", ")),
+	}
+	if assignee != nil {
+		response.Text = fmt.Sprintf("%s, assigned to %s", response.Text, assignee.Mention())
+	}
+	for _, issue := range tickets {
+		if len(issue.Skipped) > 0 {
+			response.Text = fmt.Sprintf("%s\n_%s was created without the %s_", response.Text, escapeSlack(issue.Key), escapeSlack(strings.Join(issue.Skipped, " and ")))
 		}
-	} else {
-		response = SlackResponseResult{
-			ResponseType: "in_channel",
-			Text:         fmt.Sprintf("<@%s> is `/ask`ing \"%s\" (<%s|%s>)", originalAsk.UserID, request.Submission["summary"], a.Jira.GetTicketURL(issue.Key), issue.Key),
+	}
+	if len(failures) > 0 {
+		// The errors can have whatever the backend said in them, so only the asker sees them
+		err := a.respondToAsker(token, originalAsk, SlackResponseResult{
+			ResponseType: "ephemeral",
+			Text:         fmt.Sprintf("Some tickets could not be created:\n%s", strings.Join(failures, "\n")),
+		})
+		if err != nil {
+			log.Printf("Unable to tell %s which tickets failed: %v\n", originalAsk.UserID, err)
 		}
 	}
 
-	responseJson, err := json.Marshal(response)
+	if a.hasAskActions(tickets) {
+		response.Blocks = askMessageBlocks(response.Text, &storage.AskMessage{})
+	}
+
+	// Post as the bot so we know where the message is, falling back to the
+	// response_url for channels the bot can't post in
+	message, err := chatPostMessage(token, originalAsk.ChannelID, "", response)
 	if err != nil {
-		log.Printf("Error encoding JSON: %+v\n", err)
-		return err
+		log.Printf("Unable to post the ask to %s, using the response_url instead: %v\n", originalAsk.ChannelID, err)
+		// Without the message stored the buttons can't do anything
+		response.Blocks = nil
+		return a.respondToAsker(token, originalAsk, response)
+	}
+
+	for _, ticket := range tickets {
+		if ticket.Key == "" {
+			// Nothing to find it by later
+			continue
+		}
+		err := db.StoreAskMessage(&storage.AskMessage{
+			TeamID:    originalAsk.TeamID,
+			Backend:   ticket.Backend,
+			Project:   ticket.Project,
+			Key:       ticket.Key,
+			URL:       ticket.URL,
+			Summary:   ticket.Summary,
+			ChannelID: message.Channel,
+			Timestamp: message.Timestamp,
+			UserID:    originalAsk.UserID,
+			Created:   time.Now().Unix(),
+		})
+		if err != nil {
+			log.Printf("Unable to store the message for %s, updates will not be posted to the thread: %v\n", ticket.Key, err)
+		}
 	}
 
-	req, err := http.NewRequest("POST", originalAsk.ResponseURL, bytes.NewBuffer(responseJson))
-	req.Header.Set("Content-Type", "application/json")
+	a.linkTicketsToMessage(token, tickets, message)
+	return nil
+}
+
+// respondToAsker replies through the response_url, or by DM for asks from
+// shortcuts that don't have one
+func (a *Asker) respondToAsker(token string, originalAsk *storage.SlashCommand, response SlackResponseResult) error {
+	if originalAsk.ResponseURL != "" {
+		return respond(originalAsk.ResponseURL, response)
+	}
+	_, err := chatPostMessage(token, originalAsk.UserID, "", response)
+	return err
+}
 
-	req = req.WithContext(context.Background())
-	resp, err := getHTTPClient().Do(req)
+// linkTicketsToMessage adds a link back to the ask's Slack message on backends that support it
+func (a *Asker) linkTicketsToMessage(token string, tickets []*Ticket, message *SlackMessage) {
+	permalink, err := chatGetPermalink(token, message.Channel, message.Timestamp)
 	if err != nil {
-		log.Printf("Error posting response back to Slack: %s\n", err)
-		return err
+		log.Printf("Unable to link tickets back to Slack: %v\n", err)
+		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		logResponse(resp, true)
-		return fmt.Errorf("Slack server error: %s.", resp.Status)
+	for _, ticket := range tickets {
+		backend, err := a.GetBackend(ticket.Backend)
+		if err != nil {
+			continue
+		}
+		if linker, ok := backend.(RemoteLinker); ok {
+			if err := linker.AddRemoteLink(ticket, permalink, "Slack: "+ticket.Summary); err != nil {
+				log.Printf("Unable to link %s back to Slack: %v\n", ticket.Key, err)
+			}
+		}
 	}
+}
 
-	return nil
+// slackContext describes where the ask came from, for whoever picks up the ticket
+func slackContext(originalAsk *storage.SlashCommand) string {
+	channelURL := fmt.Sprintf("https://%s.slack.com/archives/%s", originalAsk.TeamDomain, originalAsk.ChannelID)
+	return strings.Join([]string{
+		"Asked in Slack",
+		fmt.Sprintf("Channel: #%s (%s)", originalAsk.ChannelName, channelURL),
+		fmt.Sprintf("Asked by: %s (%s)", originalAsk.UserName, originalAsk.UserID),
+		fmt.Sprintf("Workspace: %s", originalAsk.TeamDomain),
+		fmt.Sprintf("Asked at: %s", time.Unix(originalAsk.Timestamp, 0).UTC().Format(time.RFC1123)),
+	}, "\n")
 }
@@ -0,0 +1,113 @@
+package asker
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jshirley/slack-ask/storage"
+)
+
+// fakeBackend records the tickets asked of it, numbering them from 1
+type fakeBackend struct {
+	requests []*TicketRequest
+	err      error
+}
+
+func (f *fakeBackend) CreateTicket(request *TicketRequest) (*Ticket, error) {
+	f.requests = append(f.requests, request)
+	if f.err != nil {
+		return nil, f.err
+	}
+	key := fmt.Sprintf("%s-%d", request.ProjectKey, len(f.requests))
+	return &Ticket{Key: key, URL: f.GetTicketURL(request.ProjectKey, key), Summary: request.Summary}, nil
+}
+
+func (f *fakeBackend) GetTicket(project string, key string) (*Ticket, error) {
+	return &Ticket{Key: key, URL: f.GetTicketURL(project, key)}, nil
+}
+
+func (f *fakeBackend) GetTicketURL(project string, key string) string {
+	return "https://tickets.example.com/" + key
+}
+
+func (f *fakeBackend) GetComponents(project string) ([]string, error) {
+	return nil, nil
+}
+
+func TestCreateTicketsInEveryTarget(t *testing.T) {
+	jira, github, broken := &fakeBackend{}, &fakeBackend{}, &fakeBackend{err: fmt.Errorf("Service Unavailable")}
+	ask := &Asker{}
+	ask.RegisterBackend("jira", jira)
+	ask.RegisterBackend("github", github)
+	ask.RegisterBackend("broken", broken)
+
+	// No team, so the token is the default one and the db is never needed
+	originalAsk := &storage.SlashCommand{
+		UserName:    "jane",
+		UserID:      "U1",
+		ChannelID:   "C1",
+		ChannelName: "help",
+		TeamDomain:  "acme",
+		Config: &storage.ChannelConfig{
+			Targets: []storage.LinkTarget{
+				{Project: "PROJ"},
+				{Backend: "github", Project: "org/repo"},
+				{Backend: "broken", Project: "DOWN"},
+				{Backend: "gitlab", Project: "group/project"},
+			},
+			Components: []string{"api"},
+		},
+	}
+	request := &InteractiveRequest{Submission: map[string]string{"summary": "*Help*", "description": "It's broken", "blocking": "911"}}
+
+	tickets, failures := ask.createTickets(nil, originalAsk, request, &Assignee{Name: "sam"})
+
+	var created []string
+	for _, ticket := range tickets {
+		created = append(created, ticket.Backend+" "+ticket.Project+" "+ticket.Key)
+	}
+	if want := []string{"jira PROJ PROJ-1", "github org/repo org/repo-1"}; !reflect.DeepEqual(created, want) {
+		t.Errorf("Created %v, want %v", created, want)
+	}
+
+	// Every target that failed is reported, and the rest still get their ticket
+	if len(failures) != 2 ||
+		!strings.Contains(failures[0], "broken project DOWN: `Service Unavailable`") ||
+		!strings.Contains(failures[1], "gitlab project group/project: `The `gitlab` backend is not configured`") {
+		t.Errorf("Failures are %q", failures)
+	}
+
+	// Each backend gets the same ask
+	for name, backend := range map[string]*fakeBackend{"jira": jira, "github": github, "broken": broken} {
+		if len(backend.requests) != 1 {
+			t.Errorf("%s was asked %d times, want once", name, len(backend.requests))
+			continue
+		}
+		got := backend.requests[0]
+		if got.Summary != "Help" || got.Assignee != "sam" || got.Priority != "Highest" || got.UserID != "U1" {
+			t.Errorf("%s was asked for %+v", name, got)
+		}
+		if !reflect.DeepEqual(got.Labels, []string{"urgent"}) || !reflect.DeepEqual(got.Components, []string{"api"}) {
+			t.Errorf("%s was asked with labels %v and components %v", name, got.Labels, got.Components)
+		}
+		if !strings.HasPrefix(got.Description, "It's broken\n\nAsked in Slack\nChannel: #help (https://acme.slack.com/archives/C1)") {
+			t.Errorf("%s was asked with the description %q", name, got.Description)
+		}
+	}
+	if jira.requests[0].ProjectKey != "PROJ" || github.requests[0].ProjectKey != "org/repo" {
+		t.Errorf("Asked for projects %q and %q", jira.requests[0].ProjectKey, github.requests[0].ProjectKey)
+	}
+}
+
+func TestCreateTicketsAllFail(t *testing.T) {
+	ask := &Asker{}
+	ask.RegisterBackend("jira", &fakeBackend{err: fmt.Errorf("Unauthorized")})
+
+	originalAsk := &storage.SlashCommand{Config: &storage.ChannelConfig{Project: "PROJ"}}
+	tickets, failures := ask.createTickets(nil, originalAsk, &InteractiveRequest{Submission: map[string]string{"summary": "Help"}}, nil)
+	if len(tickets) != 0 || len(failures) != 1 || failures[0] != "jira project PROJ: `Unauthorized`" {
+		t.Errorf("createTickets returned %v, %q", tickets, failures)
+	}
+}
@@ -2,6 +2,11 @@ package asker
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jshirley/slack-ask/storage"
 )
 
 type DialogOption struct {
@@ -30,6 +35,26 @@ type Dialog struct {
 	Elements    []DialogElement `json:"elements" mapstructure:"dialog"`
 }
 
+// ELEMENT_TYPES are the inputs the ask form can have
+var ELEMENT_TYPES = []string{"text", "textarea", "select", "multi_select", "checkboxes", "user", "channel", "date"}
+
+// Limits on what modals will show
+const (
+	MAX_LABEL_LENGTH       = 2000
+	MAX_PLACEHOLDER_LENGTH = 150
+	MAX_OPTION_LENGTH      = 75
+	MAX_CHECKBOXES         = 10
+)
+
+func validElementType(elementType string) bool {
+	for _, valid := range ELEMENT_TYPES {
+		if elementType == valid {
+			return true
+		}
+	}
+	return false
+}
+
 func (a *Asker) SetDialogElements(inDialog Dialog) error {
 	if len(inDialog.Elements) < 1 {
 		a.dialogElements = defaultElements()
@@ -44,25 +69,39 @@ func (a *Asker) SetDialogElements(inDialog Dialog) error {
 		if element.Type == "" {
 			return fmt.Errorf("Element `%s` does not have required `type` field, check configuration", element.Name)
 		}
-		if element.Type != "text" && element.Type != "textarea" && element.Type != "select" {
-			return fmt.Errorf("Element `%s` has an invalid `type` field (%s is not text, textarea, or select)", element.Name, element.Type)
+		if !validElementType(element.Type) {
+			return fmt.Errorf("Element `%s` has an invalid `type` field (%s is not %s)", element.Name, element.Type, strings.Join(ELEMENT_TYPES, ", "))
 		}
 		if element.Label == "" {
 			return fmt.Errorf("Element `%s` does not have required `label` field, check configuration", element.Name)
 		}
-		if len(element.Label) > 24 {
-			return fmt.Errorf("Element `%s`' label is over 24 characters, check configuration", element.Name)
+		if len(element.Label) > MAX_LABEL_LENGTH {
+			return fmt.Errorf("Element `%s`' label is over %d characters, check configuration", element.Name, MAX_LABEL_LENGTH)
+		}
+		if len(element.Placeholder) > MAX_PLACEHOLDER_LENGTH {
+			return fmt.Errorf("Element `%s`' placeholder is over %d characters, check configuration", element.Name, MAX_PLACEHOLDER_LENGTH)
+		}
+		if element.Type == "date" && element.Value != "" {
+			if _, err := time.Parse("2006-01-02", element.Value); err != nil {
+				return fmt.Errorf("Element `%s` is a date, but `%s` is not YYYY-MM-DD! Check configuration", element.Name, element.Value)
+			}
 		}
-		if element.Type == "select" {
-			if len(element.Options) < 0 {
-				return fmt.Errorf("Element `%s` is a select, but has no options! Check configuration", element.Name)
+		if element.Type == "select" || element.Type == "multi_select" || element.Type == "checkboxes" {
+			if len(element.Options) < 1 {
+				return fmt.Errorf("Element `%s` is a %s, but has no options! Check configuration", element.Name, element.Type)
+			}
+			if element.Type == "checkboxes" && len(element.Options) > MAX_CHECKBOXES {
+				return fmt.Errorf("Element `%s` has more than %d checkboxes, use a multi_select instead", element.Name, MAX_CHECKBOXES)
 			}
 			for _, option := range element.Options {
 				if option.Label == "" || option.Value == "" {
 					return fmt.Errorf("Element `%s` options require both label and value! Check configuration", element.Name)
 				}
-				if len(option.Label) > 24 {
-					return fmt.Errorf("Element `%s` option label `%s` is over 24 characters, check configuration", element.Name, option.Label)
+				if len(option.Label) > MAX_OPTION_LENGTH {
+					return fmt.Errorf("Element `%s` option label `%s` is over %d characters, check configuration", element.Name, option.Label, MAX_OPTION_LENGTH)
+				}
+				if element.Type != "select" && strings.Contains(option.Value, ",") {
+					return fmt.Errorf("Element `%s` option value `%s` can't have a comma, multiple answers are joined with them", element.Name, option.Value)
 				}
 			}
 		}
@@ -81,6 +120,47 @@ func (a *Asker) GetDialog(callback string) Dialog {
 	}
 }
 
+// defaultPriorities maps the `blocking` answers in defaultElements, channels
+// can override each answer with `/ask config priority`
+func defaultPriorities() map[string]storage.PriorityMapping {
+	return map[string]storage.PriorityMapping{
+		"yes": storage.PriorityMapping{Priority: "High"},
+		"911": storage.PriorityMapping{Priority: "Highest", Labels: []string{"urgent"}},
+	}
+}
+
+// priorityFor looks up the mapping for a `blocking` answer, preferring the channel's own
+func priorityFor(config *storage.ChannelConfig, value string) storage.PriorityMapping {
+	if mapping, ok := config.Priorities[value]; ok {
+		return mapping
+	}
+	return defaultPriorities()[value]
+}
+
+func describePriorities(config *storage.ChannelConfig) string {
+	values := []string{}
+	for value := range defaultPriorities() {
+		values = append(values, value)
+	}
+	for value := range config.Priorities {
+		if _, ok := defaultPriorities()[value]; !ok {
+			values = append(values, value)
+		}
+	}
+	sort.Strings(values)
+
+	var described []string
+	for _, value := range values {
+		mapping := priorityFor(config, value)
+		if len(mapping.Labels) > 0 {
+			described = append(described, fmt.Sprintf("%s → %s (%s)", value, mapping.Priority, strings.Join(mapping.Labels, ", ")))
+		} else {
+			described = append(described, fmt.Sprintf("%s → %s", value, mapping.Priority))
+		}
+	}
+	return strings.Join(described, ", ")
+}
+
 func defaultElements() []DialogElement {
 	return []DialogElement{
 		DialogElement{Type: "text", Label: "The one liner...", Name: "summary"},
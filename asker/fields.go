@@ -0,0 +1,125 @@
+package asker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FieldMapping sends the answer to a dialog element somewhere on the ticket.
+// Field is a JIRA field (like `environment` or `customfield_10010`), `label`,
+// or `description` to append it to the description under Title.
+type FieldMapping struct {
+	Element string `mapstructure:"element"`
+	Field   string `mapstructure:"field"`
+	Title   string `mapstructure:"title"`
+	// Format is how the value is sent for JIRA fields: `text` (the default),
+	// `option` for select lists, `name` for fields like versions, or `number`
+	Format string `mapstructure:"format"`
+}
+
+// richText is a textarea answer, which JIRA needs converted like the description
+type richText string
+
+func (a *Asker) SetFieldMappings(mappings []FieldMapping) error {
+	for _, mapping := range mappings {
+		if mapping.Element == "" || mapping.Field == "" {
+			return fmt.Errorf("Field mappings require both `element` and `field`, check configuration")
+		}
+
+		found := false
+		for _, element := range a.dialogElements {
+			if element.Name == mapping.Element {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("Field mapping is for `%s`, which is not a dialog element! Check configuration", mapping.Element)
+		}
+
+		switch mapping.Format {
+		case "", "text", "option", "name", "number":
+		default:
+			return fmt.Errorf("Field mapping for `%s` has an invalid `format` (%s is not text, option, name, or number)", mapping.Element, mapping.Format)
+		}
+	}
+	a.fieldMappings = mappings
+
+	return nil
+}
+
+// applyFieldMappings copies the dialog answers onto the ticket request
+func (a *Asker) applyFieldMappings(ticket *TicketRequest, submission map[string]string) error {
+	for _, mapping := range a.fieldMappings {
+		value := submission[mapping.Element]
+		if value == "" {
+			continue
+		}
+
+		switch mapping.Field {
+		case "summary":
+			ticket.Summary = value
+		case "description":
+			title := mapping.Title
+			if title == "" {
+				title = mapping.Element
+			}
+			ticket.Description = strings.TrimSpace(fmt.Sprintf("%s\n\n%s:\n%s", ticket.Description, title, value))
+		case "label", "labels":
+			ticket.Labels = append(ticket.Labels, strings.Fields(strings.Replace(value, ",", " ", -1))...)
+		case "components":
+			ticket.Components = append(ticket.Components, strings.Split(value, ",")...)
+		case "priority":
+			ticket.Priority = value
+		default:
+			if ticket.Fields == nil {
+				ticket.Fields = map[string]interface{}{}
+			}
+			switch mapping.Format {
+			case "option", "name":
+				key := "value"
+				if mapping.Format == "name" {
+					key = "name"
+				}
+				if a.isMultipleChoice(mapping.Element) {
+					var values []map[string]string
+					for _, choice := range strings.Split(value, ",") {
+						values = append(values, map[string]string{key: choice})
+					}
+					ticket.Fields[mapping.Field] = values
+				} else {
+					ticket.Fields[mapping.Field] = map[string]string{key: value}
+				}
+			case "number":
+				number, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					return fmt.Errorf("`%s` needs to be a number, not `%s`", mapping.Element, value)
+				}
+				ticket.Fields[mapping.Field] = number
+			default:
+				if a.elementType(mapping.Element) == "textarea" {
+					ticket.Fields[mapping.Field] = richText(value)
+				} else {
+					ticket.Fields[mapping.Field] = value
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// isMultipleChoice is whether the element's answer is a comma separated list
+func (a *Asker) isMultipleChoice(name string) bool {
+	kind := a.elementType(name)
+	return kind == "multi_select" || kind == "checkboxes"
+}
+
+func (a *Asker) elementType(name string) string {
+	for _, element := range a.dialogElements {
+		if element.Name == name {
+			return element.Type
+		}
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+package asker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyFieldMappings(t *testing.T) {
+	a := &Asker{dialogElements: append(defaultElements(),
+		DialogElement{Type: "textarea", Name: "environment"},
+		DialogElement{Type: "text", Name: "build"},
+		DialogElement{Type: "select", Name: "team"},
+		DialogElement{Type: "multi_select", Name: "versions"},
+		DialogElement{Type: "text", Name: "estimate"},
+		DialogElement{Type: "text", Name: "tags"},
+		DialogElement{Type: "checkboxes", Name: "areas"},
+		DialogElement{Type: "textarea", Name: "steps"},
+	)}
+
+	tests := []struct {
+		mapping FieldMapping
+		value   string
+		want    TicketRequest
+		err     bool
+	}{
+		{FieldMapping{Element: "summary", Field: "summary"}, "New summary", TicketRequest{Summary: "New summary", Description: "Details"}, false},
+		{FieldMapping{Element: "steps", Field: "description", Title: "Steps to reproduce"}, "1. Click", TicketRequest{Description: "Details\n\nSteps to reproduce:\n1. Click"}, false},
+		{FieldMapping{Element: "steps", Field: "description"}, "1. Click", TicketRequest{Description: "Details\n\nsteps:\n1. Click"}, false},
+		{FieldMapping{Element: "tags", Field: "label"}, "a, b c", TicketRequest{Description: "Details", Labels: []string{"a", "b", "c"}}, false},
+		{FieldMapping{Element: "areas", Field: "components"}, "api,ui", TicketRequest{Description: "Details", Components: []string{"api", "ui"}}, false},
+		{FieldMapping{Element: "team", Field: "priority"}, "High", TicketRequest{Description: "Details", Priority: "High"}, false},
+		{FieldMapping{Element: "environment", Field: "environment"}, "*prod*", TicketRequest{Description: "Details", Fields: map[string]interface{}{"environment": richText("*prod*")}}, false},
+		{FieldMapping{Element: "build", Field: "customfield_1"}, "1234", TicketRequest{Description: "Details", Fields: map[string]interface{}{"customfield_1": "1234"}}, false},
+		{FieldMapping{Element: "team", Field: "customfield_2", Format: "option"}, "core", TicketRequest{Description: "Details", Fields: map[string]interface{}{"customfield_2": map[string]string{"value": "core"}}}, false},
+		{FieldMapping{Element: "versions", Field: "fixVersions", Format: "name"}, "1.0,1.1", TicketRequest{Description: "Details", Fields: map[string]interface{}{"fixVersions": []map[string]string{{"name": "1.0"}, {"name": "1.1"}}}}, false},
+		{FieldMapping{Element: "estimate", Field: "customfield_3", Format: "number"}, "2.5", TicketRequest{Description: "Details", Fields: map[string]interface{}{"customfield_3": 2.5}}, false},
+		{FieldMapping{Element: "estimate", Field: "customfield_3", Format: "number"}, "soon", TicketRequest{}, true},
+		{FieldMapping{Element: "build", Field: "customfield_1"}, "", TicketRequest{Description: "Details"}, false},
+	}
+	for _, test := range tests {
+		if err := a.SetFieldMappings([]FieldMapping{test.mapping}); err != nil {
+			t.Fatalf("SetFieldMappings(%+v) returned %v", test.mapping, err)
+		}
+
+		ticket := TicketRequest{Description: "Details"}
+		err := a.applyFieldMappings(&ticket, map[string]string{test.mapping.Element: test.value})
+		if test.err {
+			if err == nil {
+				t.Errorf("Mapping %q with %+v = %+v, want an error", test.value, test.mapping, ticket)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(ticket, test.want) {
+			t.Errorf("Mapping %q with %+v = %+v, %v, want %+v", test.value, test.mapping, ticket, err, test.want)
+		}
+	}
+}
+
+func TestSetFieldMappings(t *testing.T) {
+	a := &Asker{dialogElements: defaultElements()}
+
+	tests := []struct {
+		mapping FieldMapping
+		valid   bool
+	}{
+		{FieldMapping{Element: "description", Field: "environment"}, true},
+		{FieldMapping{Element: "description", Field: "customfield_1", Format: "number"}, true},
+		{FieldMapping{Element: "description"}, false},
+		{FieldMapping{Element: "missing", Field: "environment"}, false},
+		{FieldMapping{Element: "description", Field: "environment", Format: "html"}, false},
+	}
+	for _, test := range tests {
+		err := a.SetFieldMappings([]FieldMapping{test.mapping})
+		if test.valid && err != nil {
+			t.Errorf("SetFieldMappings(%+v) returned %v", test.mapping, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("SetFieldMappings(%+v) was accepted, want an error", test.mapping)
+		}
+	}
+}
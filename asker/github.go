@@ -0,0 +1,197 @@
+package asker
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+)
+
+const GITHUB_API = "https://api.github.com"
+
+type GithubClient struct {
+	endpoint       string
+	publicEndpoint string
+	token          string
+}
+
+type githubIssue struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	State   string `json:"state"`
+	HTMLURL string `json:"html_url"`
+}
+
+type githubRepo struct {
+	HasIssues bool `json:"has_issues"`
+}
+
+type githubLabel struct {
+	Name string `json:"name"`
+}
+
+// NewGithub takes the REST API endpoint, which is https://api.github.com unless
+// this is a GitHub Enterprise install (usually https://github.example.com/api/v3)
+func (ask *Asker) NewGithub(endpoint string, token string) (*GithubClient, error) {
+	if endpoint == "" {
+		endpoint = GITHUB_API
+	}
+	endpoint = strings.TrimRight(endpoint, "/")
+
+	if _, err := url.Parse(endpoint); err != nil {
+		return nil, err
+	}
+
+	publicEndpoint := "https://github.com"
+	if endpoint != GITHUB_API {
+		publicEndpoint = strings.TrimSuffix(endpoint, "/api/v3")
+	}
+	log.Printf("Got GitHub endpoint to use: %s\n", endpoint)
+
+	return &GithubClient{endpoint: endpoint, publicEndpoint: publicEndpoint, token: token}, nil
+}
+
+func (g *GithubClient) headers() map[string]string {
+	return map[string]string{
+		"Accept":        "application/vnd.github.v3+json",
+		"Authorization": "token " + g.token,
+	}
+}
+
+func (g *GithubClient) repoURL(repo string) (string, error) {
+	parts := strings.Split(repo, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("GitHub projects must be in the form of org/repo, not `%s`", repo)
+	}
+	return fmt.Sprintf("%s/repos/%s/%s", g.endpoint, url.PathEscape(parts[0]), url.PathEscape(parts[1])), nil
+}
+
+// issueNumber accepts either the bare number or our `org/repo#123` keys
+func (g *GithubClient) issueNumber(key string) string {
+	if i := strings.LastIndex(key, "#"); i >= 0 {
+		return key[i+1:]
+	}
+	return key
+}
+
+func (g *GithubClient) ticketFromIssue(repo string, issue *githubIssue) *Ticket {
+	return &Ticket{
+		Key:     fmt.Sprintf("%s#%d", repo, issue.Number),
+		URL:     issue.HTMLURL,
+		Summary: issue.Title,
+		Status:  issue.State,
+	}
+}
+
+func (g *GithubClient) CreateTicket(issueRequest *TicketRequest) (*Ticket, error) {
+	repoURL, err := g.repoURL(issueRequest.ProjectKey)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := map[string]interface{}{
+		"title": issueRequest.Summary,
+		"body":  issueRequest.Description,
+	}
+	labels := append(append([]string{}, issueRequest.Components...), issueRequest.Labels...)
+	if len(labels) > 0 {
+		payload["labels"] = labels
+	}
+
+	issue := githubIssue{}
+	if err := doJSONRequest("POST", repoURL+"/issues", g.headers(), payload, &issue); err != nil {
+		log.Printf("Unable to create GitHub issue in `%s`: %s\n", issueRequest.ProjectKey, err)
+		return nil, err
+	}
+
+	ticket := g.ticketFromIssue(issueRequest.ProjectKey, &issue)
+
+	// Assigned separately, so a name that isn't a GitHub login only leaves it unassigned
+	if issueRequest.Assignee != "" {
+		if err := g.AssignTicket(issueRequest.ProjectKey, ticket.Key, &BackendUser{Name: issueRequest.Assignee}); err != nil {
+			log.Printf("Unable to assign %s to %s, leaving it unassigned: %v\n", ticket.Key, issueRequest.Assignee, err)
+		}
+	}
+
+	return ticket, nil
+}
+
+func (g *GithubClient) AssignTicket(repo string, key string, user *BackendUser) error {
+	repoURL, err := g.repoURL(repo)
+	if err != nil {
+		return err
+	}
+
+	payload := map[string][]string{"assignees": []string{user.Name}}
+	issue := struct {
+		Assignees []struct {
+			Login string `json:"login"`
+		} `json:"assignees"`
+	}{}
+	if err := doJSONRequest("POST", repoURL+"/issues/"+g.issueNumber(key)+"/assignees", g.headers(), payload, &issue); err != nil {
+		return err
+	}
+	// GitHub quietly skips people who can't be assigned
+	for _, assignee := range issue.Assignees {
+		if strings.ToLower(assignee.Login) == strings.ToLower(user.Name) {
+			return nil
+		}
+	}
+	return fmt.Errorf("`%s` can't be assigned issues in %s", user.Name, repo)
+}
+
+func (g *GithubClient) GetTicket(repo string, key string) (*Ticket, error) {
+	repoURL, err := g.repoURL(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	issue := githubIssue{}
+	if err := doJSONRequest("GET", repoURL+"/issues/"+g.issueNumber(key), g.headers(), nil, &issue); err != nil {
+		return nil, err
+	}
+
+	return g.ticketFromIssue(repo, &issue), nil
+}
+
+func (g *GithubClient) GetTicketURL(repo string, key string) string {
+	return fmt.Sprintf("%s/%s/issues/%s", g.publicEndpoint, repo, g.issueNumber(key))
+}
+
+// GetComponents lists the repository labels, which is what components map to
+func (g *GithubClient) GetComponents(repo string) ([]string, error) {
+	repoURL, err := g.repoURL(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var labels []githubLabel
+	if err := doJSONRequest("GET", repoURL+"/labels?per_page=100", g.headers(), nil, &labels); err != nil {
+		return nil, err
+	}
+
+	var components []string
+	for _, label := range labels {
+		components = append(components, label.Name)
+	}
+	return components, nil
+}
+
+func (g *GithubClient) ValidateProject(repo string) error {
+	repoURL, err := g.repoURL(repo)
+	if err != nil {
+		return err
+	}
+
+	result := githubRepo{}
+	if err := doJSONRequest("GET", repoURL, g.headers(), nil, &result); err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("GitHub repository `%s` was not found", repo)
+		}
+		return fmt.Errorf("Unable to check GitHub repository `%s`: %v", repo, err)
+	}
+	if !result.HasIssues {
+		return fmt.Errorf("GitHub repository `%s` has issues turned off", repo)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package asker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// githubStandIn answers the parts of the GitHub REST API the backend uses,
+// recording the issues created through it
+func githubStandIn(t *testing.T, created *[]map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token secret-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("Accept") != "application/vnd.github.v3+json" {
+			t.Errorf("Accept header is %q", r.Header.Get("Accept"))
+		}
+
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/repos/org/repo/issues":
+			payload := map[string]interface{}{}
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				t.Errorf("Unable to decode the issue: %v", err)
+			}
+			*created = append(*created, payload)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(githubIssue{Number: 42, Title: payload["title"].(string), State: "open", HTMLURL: "https://github.com/org/repo/issues/42"})
+		case r.Method == "POST" && r.URL.Path == "/repos/org/repo/issues/42/assignees":
+			payload := map[string][]string{}
+			json.NewDecoder(r.Body).Decode(&payload)
+			var assigned []map[string]string
+			for _, login := range payload["assignees"] {
+				if login == "octocat" {
+					assigned = append(assigned, map[string]string{"login": login})
+				}
+			}
+			*created = append(*created, map[string]interface{}{"assigned": len(assigned)})
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]interface{}{"number": 42, "assignees": assigned})
+		case r.Method == "GET" && r.URL.Path == "/repos/org/repo/issues/42":
+			json.NewEncoder(w).Encode(githubIssue{Number: 42, Title: "Help", State: "closed", HTMLURL: "https://github.com/org/repo/issues/42"})
+		case r.Method == "GET" && r.URL.Path == "/repos/org/repo/labels":
+			json.NewEncoder(w).Encode([]githubLabel{{Name: "bug"}, {Name: "question"}})
+		case r.Method == "GET" && r.URL.Path == "/repos/org/repo":
+			json.NewEncoder(w).Encode(githubRepo{HasIssues: true})
+		case r.Method == "GET" && r.URL.Path == "/repos/org/noissues":
+			json.NewEncoder(w).Encode(githubRepo{HasIssues: false})
+		case r.URL.Path == "/repos/org/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestGithubCreateTicket(t *testing.T) {
+	var created []map[string]interface{}
+	server := githubStandIn(t, &created)
+	defer server.Close()
+
+	client, err := (&Asker{}).NewGithub(server.URL+"/", "secret-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ticket, err := client.CreateTicket(&TicketRequest{
+		ProjectKey:  "org/repo",
+		Summary:     "Help",
+		Description: "It's broken",
+		Assignee:    "octocat",
+		Components:  []string{"bug"},
+		Labels:      []string{"urgent"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Ticket{Key: "org/repo#42", URL: "https://github.com/org/repo/issues/42", Summary: "Help", Status: "open"}
+	if !reflect.DeepEqual(ticket, want) {
+		t.Errorf("CreateTicket returned %+v, want %+v", ticket, want)
+	}
+
+	// Assigned after it's created
+	wantRequests := []map[string]interface{}{
+		{"title": "Help", "body": "It's broken", "labels": []interface{}{"bug", "urgent"}},
+		{"assigned": 1},
+	}
+	if !reflect.DeepEqual(created, wantRequests) {
+		t.Errorf("Sent %+v, want %+v", created, wantRequests)
+	}
+
+	// Someone GitHub can't assign still gets their issue
+	created = nil
+	ticket, err = client.CreateTicket(&TicketRequest{ProjectKey: "org/repo", Summary: "Help", Assignee: "Jane Doe"})
+	if err != nil || ticket.Key != "org/repo#42" {
+		t.Errorf("Creating with an unknown assignee returned %+v, %v", ticket, err)
+	}
+	if len(created) != 2 || created[1]["assigned"] != 0 {
+		t.Errorf("Sent %+v, want the issue and an attempt to assign it", created)
+	}
+	if err := client.AssignTicket("org/repo", "org/repo#42", &BackendUser{Name: "Jane Doe"}); err == nil {
+		t.Errorf("Assigning someone GitHub skipped didn't return an error")
+	}
+
+	if _, err := client.CreateTicket(&TicketRequest{ProjectKey: "org/missing", Summary: "Help"}); !isNotFound(err) {
+		t.Errorf("Creating in a missing repository returned %v", err)
+	}
+}
+
+func TestGithubGetTicket(t *testing.T) {
+	server := githubStandIn(t, &[]map[string]interface{}{})
+	defer server.Close()
+
+	client, _ := (&Asker{}).NewGithub(server.URL, "secret-token")
+
+	ticket, err := client.GetTicket("org/repo", "org/repo#42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ticket.Key != "org/repo#42" || ticket.Status != "closed" {
+		t.Errorf("GetTicket returned %+v", ticket)
+	}
+
+	components, err := client.GetComponents("org/repo")
+	if err != nil || !reflect.DeepEqual(components, []string{"bug", "question"}) {
+		t.Errorf("GetComponents returned %v, %v", components, err)
+	}
+}
+
+func TestGithubValidateProject(t *testing.T) {
+	server := githubStandIn(t, &[]map[string]interface{}{})
+	defer server.Close()
+
+	client, _ := (&Asker{}).NewGithub(server.URL, "secret-token")
+
+	tests := []struct {
+		repo string
+		err  string
+	}{
+		{"org/repo", ""},
+		{"org/noissues", "has issues turned off"},
+		{"org/missing", "was not found"},
+		{"org/broken", "Unable to check"},
+		{"repo", "must be in the form of org/repo"},
+	}
+	for _, test := range tests {
+		err := client.ValidateProject(test.repo)
+		if test.err == "" && err != nil {
+			t.Errorf("ValidateProject(%q) returned %v", test.repo, err)
+		} else if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("ValidateProject(%q) returned %v, want an error containing %q", test.repo, err, test.err)
+		}
+	}
+}
+
+func TestGithubTicketURL(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     string
+	}{
+		{"", "https://github.com/org/repo/issues/42"},
+		{"https://github.example.com/api/v3/", "https://github.example.com/org/repo/issues/42"},
+	}
+	for _, test := range tests {
+		client, _ := (&Asker{}).NewGithub(test.endpoint, "secret-token")
+		if got := client.GetTicketURL("org/repo", "org/repo#42"); got != test.want {
+			t.Errorf("GetTicketURL with %q is %q, want %q", test.endpoint, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package asker
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+)
+
+type GitlabClient struct {
+	endpoint string
+	token    string
+}
+
+type gitlabIssue struct {
+	IID    int    `json:"iid"`
+	Title  string `json:"title"`
+	State  string `json:"state"`
+	WebURL string `json:"web_url"`
+}
+
+type gitlabProject struct {
+	IssuesEnabled bool `json:"issues_enabled"`
+}
+
+type gitlabLabel struct {
+	Name string `json:"name"`
+}
+
+// NewGitlab takes the base URL of the GitLab install, like https://gitlab.example.com
+func (ask *Asker) NewGitlab(endpoint string, token string) (*GitlabClient, error) {
+	endpoint = strings.TrimRight(endpoint, "/")
+	if _, err := url.Parse(endpoint); err != nil {
+		return nil, err
+	}
+	log.Printf("Got GitLab endpoint to use: %s\n", endpoint)
+
+	return &GitlabClient{endpoint: endpoint, token: token}, nil
+}
+
+func (g *GitlabClient) headers() map[string]string {
+	return map[string]string{"PRIVATE-TOKEN": g.token}
+}
+
+func (g *GitlabClient) projectURL(project string) (string, error) {
+	if !strings.Contains(project, "/") {
+		return "", fmt.Errorf("GitLab projects must be in the form of group/project, not `%s`", project)
+	}
+	return fmt.Sprintf("%s/api/v4/projects/%s", g.endpoint, url.PathEscape(project)), nil
+}
+
+// issueIID accepts either the bare iid or our `group/project#123` keys
+func (g *GitlabClient) issueIID(key string) string {
+	if i := strings.LastIndex(key, "#"); i >= 0 {
+		return key[i+1:]
+	}
+	return key
+}
+
+func (g *GitlabClient) ticketFromIssue(project string, issue *gitlabIssue) *Ticket {
+	return &Ticket{
+		Key:     fmt.Sprintf("%s#%d", project, issue.IID),
+		URL:     issue.WebURL,
+		Summary: issue.Title,
+		Status:  issue.State,
+	}
+}
+
+func (g *GitlabClient) CreateTicket(issueRequest *TicketRequest) (*Ticket, error) {
+	projectURL, err := g.projectURL(issueRequest.ProjectKey)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := map[string]interface{}{
+		"title":       issueRequest.Summary,
+		"description": issueRequest.Description,
+	}
+	labels := append(append([]string{}, issueRequest.Components...), issueRequest.Labels...)
+	if len(labels) > 0 {
+		payload["labels"] = strings.Join(labels, ",")
+	}
+
+	issue := gitlabIssue{}
+	if err := doJSONRequest("POST", projectURL+"/issues", g.headers(), payload, &issue); err != nil {
+		log.Printf("Unable to create GitLab issue in `%s`: %s\n", issueRequest.ProjectKey, err)
+		return nil, err
+	}
+
+	return g.ticketFromIssue(issueRequest.ProjectKey, &issue), nil
+}
+
+func (g *GitlabClient) GetTicket(project string, key string) (*Ticket, error) {
+	projectURL, err := g.projectURL(project)
+	if err != nil {
+		return nil, err
+	}
+
+	issue := gitlabIssue{}
+	if err := doJSONRequest("GET", projectURL+"/issues/"+g.issueIID(key), g.headers(), nil, &issue); err != nil {
+		return nil, err
+	}
+
+	return g.ticketFromIssue(project, &issue), nil
+}
+
+func (g *GitlabClient) GetTicketURL(project string, key string) string {
+	return fmt.Sprintf("%s/%s/issues/%s", g.endpoint, project, g.issueIID(key))
+}
+
+// GetComponents lists the project labels, which is what components map to
+func (g *GitlabClient) GetComponents(project string) ([]string, error) {
+	projectURL, err := g.projectURL(project)
+	if err != nil {
+		return nil, err
+	}
+
+	var labels []gitlabLabel
+	if err := doJSONRequest("GET", projectURL+"/labels?per_page=100", g.headers(), nil, &labels); err != nil {
+		return nil, err
+	}
+
+	var components []string
+	for _, label := range labels {
+		components = append(components, label.Name)
+	}
+	return components, nil
+}
+
+func (g *GitlabClient) ValidateProject(project string) error {
+	projectURL, err := g.projectURL(project)
+	if err != nil {
+		return err
+	}
+
+	result := gitlabProject{}
+	if err := doJSONRequest("GET", projectURL, g.headers(), nil, &result); err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("GitLab project `%s` was not found", project)
+		}
+		return fmt.Errorf("Unable to check GitLab project `%s`: %v", project, err)
+	}
+	if !result.IssuesEnabled {
+		return fmt.Errorf("GitLab project `%s` has issues turned off", project)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package asker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// gitlabStandIn answers the parts of the GitLab v4 API the backend uses,
+// recording the issues created through it
+func gitlabStandIn(t *testing.T, created *[]map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "secret-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		// Projects are addressed by their escaped path, group%2Fproject
+		path := r.URL.EscapedPath()
+		switch {
+		case r.Method == "POST" && path == "/api/v4/projects/group%2Fproject/issues":
+			payload := map[string]interface{}{}
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				t.Errorf("Unable to decode the issue: %v", err)
+			}
+			*created = append(*created, payload)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(gitlabIssue{IID: 7, Title: payload["title"].(string), State: "opened", WebURL: "https://gitlab.example.com/group/project/issues/7"})
+		case r.Method == "GET" && path == "/api/v4/projects/group%2Fproject/issues/7":
+			json.NewEncoder(w).Encode(gitlabIssue{IID: 7, Title: "Help", State: "closed", WebURL: "https://gitlab.example.com/group/project/issues/7"})
+		case r.Method == "GET" && path == "/api/v4/projects/group%2Fproject/labels":
+			if r.URL.Query().Get("per_page") != "100" {
+				t.Errorf("Labels were listed with %q", r.URL.RawQuery)
+			}
+			json.NewEncoder(w).Encode([]gitlabLabel{{Name: "bug"}, {Name: "question"}})
+		case r.Method == "GET" && path == "/api/v4/projects/group%2Fproject":
+			json.NewEncoder(w).Encode(gitlabProject{IssuesEnabled: true})
+		case r.Method == "GET" && path == "/api/v4/projects/group%2Fnoissues":
+			json.NewEncoder(w).Encode(gitlabProject{IssuesEnabled: false})
+		case path == "/api/v4/projects/group%2Fbroken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestGitlabCreateTicket(t *testing.T) {
+	var created []map[string]interface{}
+	server := gitlabStandIn(t, &created)
+	defer server.Close()
+
+	client, err := (&Asker{}).NewGitlab(server.URL+"/", "secret-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ticket, err := client.CreateTicket(&TicketRequest{
+		ProjectKey:  "group/project",
+		Summary:     "Help",
+		Description: "It's broken",
+		Components:  []string{"bug"},
+		Labels:      []string{"urgent"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Ticket{Key: "group/project#7", URL: "https://gitlab.example.com/group/project/issues/7", Summary: "Help", Status: "opened"}
+	if !reflect.DeepEqual(ticket, want) {
+		t.Errorf("CreateTicket returned %+v, want %+v", ticket, want)
+	}
+
+	// GitLab takes labels as one comma separated string
+	wantRequests := []map[string]interface{}{
+		{"title": "Help", "description": "It's broken", "labels": "bug,urgent"},
+	}
+	if !reflect.DeepEqual(created, wantRequests) {
+		t.Errorf("Sent %+v, want %+v", created, wantRequests)
+	}
+
+	created = nil
+	if _, err := client.CreateTicket(&TicketRequest{ProjectKey: "group/project", Summary: "Help"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := created[0]["labels"]; ok {
+		t.Errorf("Sent %+v, want no labels", created[0])
+	}
+
+	if _, err := client.CreateTicket(&TicketRequest{ProjectKey: "group/missing", Summary: "Help"}); !isNotFound(err) {
+		t.Errorf("Creating in a missing project returned %v", err)
+	}
+	if _, err := client.CreateTicket(&TicketRequest{ProjectKey: "project", Summary: "Help"}); err == nil {
+		t.Errorf("Creating in a project without a group didn't return an error")
+	}
+}
+
+func TestGitlabGetTicket(t *testing.T) {
+	server := gitlabStandIn(t, &[]map[string]interface{}{})
+	defer server.Close()
+
+	client, _ := (&Asker{}).NewGitlab(server.URL, "secret-token")
+
+	for _, key := range []string{"group/project#7", "7"} {
+		ticket, err := client.GetTicket("group/project", key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ticket.Key != "group/project#7" || ticket.Status != "closed" {
+			t.Errorf("GetTicket(%q) returned %+v", key, ticket)
+		}
+	}
+
+	components, err := client.GetComponents("group/project")
+	if err != nil || !reflect.DeepEqual(components, []string{"bug", "question"}) {
+		t.Errorf("GetComponents returned %v, %v", components, err)
+	}
+
+	if got, want := client.GetTicketURL("group/project", "group/project#7"), server.URL+"/group/project/issues/7"; got != want {
+		t.Errorf("GetTicketURL is %q, want %q", got, want)
+	}
+}
+
+func TestGitlabValidateProject(t *testing.T) {
+	server := gitlabStandIn(t, &[]map[string]interface{}{})
+	defer server.Close()
+
+	client, _ := (&Asker{}).NewGitlab(server.URL, "secret-token")
+
+	tests := []struct {
+		project string
+		err     string
+	}{
+		{"group/project", ""},
+		{"group/noissues", "has issues turned off"},
+		{"group/missing", "was not found"},
+		{"group/broken", "Unable to check"},
+		{"project", "must be in the form of group/project"},
+	}
+	for _, test := range tests {
+		err := client.ValidateProject(test.project)
+		if test.err == "" && err != nil {
+			t.Errorf("ValidateProject(%q) returned %v", test.project, err)
+		} else if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("ValidateProject(%q) returned %v, want an error containing %q", test.project, err, test.err)
+		}
+	}
+
+	// A bad token is an error, not a missing project
+	client, _ = (&Asker{}).NewGitlab(server.URL, "wrong-token")
+	if err := client.ValidateProject("group/project"); err == nil || isNotFound(err) {
+		t.Errorf("ValidateProject with a bad token returned %v", err)
+	}
+}
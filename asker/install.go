@@ -0,0 +1,155 @@
+package asker
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jshirley/slack-ask/storage"
+
+	"github.com/nlopes/slack"
+)
+
+const SLACK_AUTHORIZE_URL = "https://slack.com/oauth/v2/authorize"
+
+// BOT_SCOPES are everything slack-ask asks for when it is installed
+var BOT_SCOPES = []string{
+	"commands",
+	"chat:write",
+	"chat:write.public",
+	"users:read",
+	"users:read.email",
+	"channels:read",
+	"channels:history",
+	"groups:read",
+	"groups:history",
+}
+
+const OAUTH_STATE_COOKIE = "slack_ask_state"
+
+type oauthAccessResponse struct {
+	slack.SlackResponse
+	AccessToken string     `json:"access_token"`
+	Scope       string     `json:"scope"`
+	BotUserID   string     `json:"bot_user_id"`
+	Team        SlackTuple `json:"team"`
+	Enterprise  SlackTuple `json:"enterprise"`
+	AuthedUser  struct {
+		Id string `json:"id"`
+	} `json:"authed_user"`
+}
+
+// teamToken is the bot token for the workspace, or the single workspace
+// --oauth token when the workspace wasn't installed through /install
+func (a *Asker) teamToken(db storage.DataLayer, teamID string) string {
+	if teamID != "" {
+		if team, err := db.GetTeam(teamID); err == nil {
+			return team.BotToken
+		}
+	}
+	return a.OAuth
+}
+
+func (a *Asker) slackAPI(token string) *slack.Client {
+	if token == a.OAuth {
+		return a.api
+	}
+	return slack.New(token)
+}
+
+func (a *Asker) redirectURL() string {
+	if a.PublicURL == "" {
+		return ""
+	}
+	return strings.TrimSuffix(a.PublicURL, "/") + "/oauth/redirect"
+}
+
+// InstallHandler sends whoever is installing slack-ask to Slack to approve it
+func (a *Asker) InstallHandler(w http.ResponseWriter, r *http.Request) {
+	if a.ClientID == "" || a.ClientSecret == "" {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "Installing into other workspaces is not set up, start slack-ask with --client and --secret")
+		return
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "Internal error")
+		return
+	}
+	state := hex.EncodeToString(nonce)
+	http.SetCookie(w, &http.Cookie{Name: OAUTH_STATE_COOKIE, Value: state, Path: "/oauth", MaxAge: 600, HttpOnly: true})
+
+	params := url.Values{
+		"client_id": {a.ClientID},
+		"scope":     {strings.Join(BOT_SCOPES, ",")},
+		"state":     {state},
+	}
+	if redirect := a.redirectURL(); redirect != "" {
+		params.Set("redirect_uri", redirect)
+	}
+	http.Redirect(w, r, SLACK_AUTHORIZE_URL+"?"+params.Encode(), http.StatusFound)
+}
+
+// OAuthRedirectHandler is where Slack sends the installer back to, exchanging
+// the code for the workspace's bot token
+func (a *Asker) OAuthRedirectHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "slack-ask was not installed: %s", query.Get("error"))
+		return
+	}
+
+	cookie, err := r.Cookie(OAUTH_STATE_COOKIE)
+	if err != nil || query.Get("state") == "" || !hmac.Equal([]byte(cookie.Value), []byte(query.Get("state"))) {
+		log.Printf("Rejecting OAuth redirect with a missing or mismatched state\n")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Bad request, try installing again")
+		return
+	}
+
+	values := url.Values{
+		"client_id":     {a.ClientID},
+		"client_secret": {a.ClientSecret},
+		"code":          {query.Get("code")},
+	}
+	if redirect := a.redirectURL(); redirect != "" {
+		values.Set("redirect_uri", redirect)
+	}
+
+	response := oauthAccessResponse{}
+	if err := post(context.Background(), "oauth.v2.access", values, &response, false); err != nil || !response.Ok {
+		log.Printf("Unable to exchange the OAuth code: %v %s\n", err, response.Error)
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Slack didn't accept the installation, try installing again")
+		return
+	}
+
+	err = MgoDBFromRequest(r).SetTeam(&storage.Team{
+		TeamID:       response.Team.Id,
+		TeamName:     response.Team.Name,
+		EnterpriseID: response.Enterprise.Id,
+		BotToken:     response.AccessToken,
+		BotUserID:    response.BotUserID,
+		Scope:        response.Scope,
+		InstalledBy:  response.AuthedUser.Id,
+	})
+	if err != nil {
+		log.Printf("Unable to store the token for %s: %v\n", response.Team.Id, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "Internal error storing the installation: %+v", err)
+		return
+	}
+
+	log.Printf("Installed into %s (%s)\n", response.Team.Name, response.Team.Id)
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "slack-ask is installed into %s! Link a channel with `/ask link <PROJECT KEY>` to start asking.", response.Team.Name)
+}
@@ -0,0 +1,92 @@
+package asker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestInstallHandler(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	(&Asker{}).InstallHandler(recorder, httptest.NewRequest("GET", "/install", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Installing without --client and --secret returned %d, want 404", recorder.Code)
+	}
+
+	ask := &Asker{ClientID: "client", ClientSecret: "secret", PublicURL: "https://ask.example.com/"}
+	recorder = httptest.NewRecorder()
+	ask.InstallHandler(recorder, httptest.NewRequest("GET", "/install", nil))
+	if recorder.Code != http.StatusFound {
+		t.Fatalf("Installing returned %d, want a redirect", recorder.Code)
+	}
+
+	location, err := url.Parse(recorder.Header().Get("Location"))
+	if err != nil || !strings.HasPrefix(location.String(), SLACK_AUTHORIZE_URL+"?") {
+		t.Fatalf("Redirected to %q, want Slack", recorder.Header().Get("Location"))
+	}
+	query := location.Query()
+	if query.Get("client_id") != "client" || query.Get("redirect_uri") != "https://ask.example.com/oauth/redirect" || query.Get("scope") != strings.Join(BOT_SCOPES, ",") {
+		t.Errorf("Redirected with %v", query)
+	}
+
+	// The state Slack sends back has to match the cookie only this browser has
+	cookies := recorder.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != OAUTH_STATE_COOKIE || !cookies[0].HttpOnly || cookies[0].Path != "/oauth" {
+		t.Fatalf("Set cookies %+v, want the HttpOnly state cookie", cookies)
+	}
+	if query.Get("state") == "" || query.Get("state") != cookies[0].Value {
+		t.Errorf("State is %q but the cookie is %q", query.Get("state"), cookies[0].Value)
+	}
+}
+
+func TestOAuthRedirectHandlerState(t *testing.T) {
+	exchanges := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/oauth.v2.access" {
+			exchanges++
+			if r.FormValue("client_secret") != "secret" || r.FormValue("code") != "c0de" {
+				t.Errorf("Exchanged %v", r.Form)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok": false, "error": "invalid_code"}`))
+	}))
+	defer server.Close()
+	SLACK_API = server.URL + "/"
+	defer func() { SLACK_API = "https://slack.com/api/" }()
+
+	ask := &Asker{ClientID: "client", ClientSecret: "secret"}
+
+	tests := []struct {
+		name     string
+		query    string
+		cookie   string
+		code     int
+		exchange bool
+	}{
+		{"no cookie", "?code=c0de&state=abc", "", http.StatusBadRequest, false},
+		{"mismatched state", "?code=c0de&state=abc", "xyz", http.StatusBadRequest, false},
+		{"no state", "?code=c0de", "xyz", http.StatusBadRequest, false},
+		{"empty state and cookie", "?code=c0de&state=", "", http.StatusBadRequest, false},
+		{"declined", "?error=access_denied", "", http.StatusOK, false},
+		{"code refused", "?code=c0de&state=abc", "abc", http.StatusBadRequest, true},
+	}
+	for _, test := range tests {
+		exchanges = 0
+		req := httptest.NewRequest("GET", "/oauth/redirect"+test.query, nil)
+		if test.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: OAUTH_STATE_COOKIE, Value: test.cookie})
+		}
+		recorder := httptest.NewRecorder()
+		ask.OAuthRedirectHandler(recorder, req)
+
+		if recorder.Code != test.code {
+			t.Errorf("%s: returned %d, want %d", test.name, recorder.Code, test.code)
+		}
+		if (exchanges > 0) != test.exchange {
+			t.Errorf("%s: exchanged the code %d times", test.name, exchanges)
+		}
+	}
+}
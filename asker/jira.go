@@ -1,64 +1,267 @@
 package asker
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
+	"net/url"
 	"strings"
+	"sync"
+
+	"github.com/jshirley/slack-ask/storage"
 
 	jira "github.com/andygrunwald/go-jira"
 )
 
 type JiraClient struct {
-	endpoint       string
-	publicEndpoint string
-	client         *jira.Client
+	endpoint        string
+	publicEndpoint  string
+	serviceReporter string
+	cloud           bool
+	client          *jira.Client
+
+	// myself is the account slack-ask uses, see Myself
+	myself     *BackendUser
+	myselfLock sync.Mutex
 }
 
-func (ask *Asker) NewJira(endpoint string, username string, password string, publicEndpoint string) (*JiraClient, error) {
+func (ask *Asker) NewJira(endpoint string, auth JiraAuth, publicEndpoint string) (*JiraClient, error) {
 	log.Printf("Got public endpoint to use: %s\n", publicEndpoint)
-	client, err := jira.NewClient(nil, endpoint)
+	httpClient, err := auth.httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := jira.NewClient(httpClient, endpoint)
 	if err != nil {
 		panic(err)
 	}
-	if username != "" {
-		client.Authentication.SetBasicAuth(username, password)
+	if auth.Mode == "cloud" && (auth.Username == "" || auth.Password == "") {
+		return nil, fmt.Errorf("JIRA Cloud authentication needs your account email (--jirauser) and an API token (--jirapass)")
+	}
+	if httpClient == nil && auth.Username != "" {
+		client.Authentication.SetBasicAuth(auth.Username, auth.Password)
 	}
 
-	return &JiraClient{endpoint: endpoint, client: client, publicEndpoint: publicEndpoint}, nil
+	return &JiraClient{endpoint: endpoint, client: client, publicEndpoint: publicEndpoint, cloud: auth.Mode == "cloud"}, nil
 }
 
-func (j *JiraClient) CreateIssue(issueRequest *TicketRequest) (*jira.Issue, error) {
+// CreateIssue creates the issue, returning what JIRA refused and it was created without
+func (j *JiraClient) CreateIssue(issueRequest *TicketRequest) (*jira.Issue, []string, error) {
 	project, _, err := j.client.Project.Get(issueRequest.ProjectKey)
 	if err != nil {
 		log.Printf("Unable to fetch JIRA project `%s`: %s\n", issueRequest.ProjectKey, err)
-		return nil, err
+		return nil, nil, err
 	}
 
 	components, err := j.getComponentsForRequest(project, issueRequest)
 	if err != nil {
 		log.Printf("Unable to fetch JIRA components for `%s`: %s\n", issueRequest.ProjectKey, err)
-		return nil, err
+		return nil, nil, err
+	}
+
+	issueType, err := j.getIssueTypeForRequest(project, issueRequest)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	i := &jira.Issue{
 		Fields: &jira.IssueFields{
-			Reporter:    &jira.User{Name: issueRequest.Username},
-			Type:        jira.IssueType{Name: project.IssueTypes[0].Name},
+			Type:        jira.IssueType{Name: issueType},
 			Project:     jira.Project{Key: issueRequest.ProjectKey},
 			Summary:     issueRequest.Summary,
 			Description: issueRequest.Description,
 			Components:  components,
+			Labels:      issueRequest.Labels,
 		},
 	}
-	issue, resp, err := j.client.Issue.Create(i)
+	if issueRequest.Priority != "" {
+		i.Fields.Priority = &jira.Priority{Name: issueRequest.Priority}
+	}
+	if len(issueRequest.Fields) > 0 {
+		i.Fields.Unknowns = issueRequest.Fields
+	}
+	j.setReporter(i, issueRequest)
+
+	var skipped []string
+	issue, resp, err := j.createIssue(i)
+	if err != nil && resp != nil && resp.StatusCode == 400 {
+		body := readErrorBody(resp)
+		// Not every project has the mapped priority, or priority and labels on its create screen
+		refused := refusedFields(body)
+		if _, ok := refused["priority"]; ok && i.Fields.Priority != nil {
+			skipped = append(skipped, fmt.Sprintf("priority `%s`", i.Fields.Priority.Name))
+			i.Fields.Priority = nil
+		}
+		if _, ok := refused["labels"]; ok && len(i.Fields.Labels) > 0 {
+			skipped = append(skipped, fmt.Sprintf("labels `%s`", strings.Join(i.Fields.Labels, " ")))
+			i.Fields.Labels = nil
+		}
+		if len(skipped) == 0 {
+			return nil, nil, fmt.Errorf(body)
+		}
+		log.Printf("JIRA refused the issue in `%s`, trying again without the %s\n", issueRequest.ProjectKey, strings.Join(skipped, " and "))
+		issue, resp, err = j.createIssue(i)
+	}
+	if err != nil {
+		if resp == nil {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf(readErrorBody(resp))
+	}
+
+	return issue, skipped, nil
+}
+
+func readErrorBody(resp *jira.Response) string {
+	bodyBytes, _ := ioutil.ReadAll(resp.Body)
+	return string(bodyBytes)
+}
+
+// refusedFields is which fields JIRA's error response complained about
+func refusedFields(body string) map[string]string {
+	response := struct {
+		Errors map[string]string `json:"errors"`
+	}{}
+	if err := json.Unmarshal([]byte(body), &response); err != nil {
+		return nil
+	}
+	return response.Errors
+}
+
+// createIssue converts the description and textarea answers from Slack's
+// formatting, which for JIRA Cloud means creating the issue through v3 of the
+// API to send ADF. The issue is copied first so it can be sent again.
+func (j *JiraClient) createIssue(i *jira.Issue) (*jira.Issue, *jira.Response, error) {
+	fields := *i.Fields
+	unknowns := map[string]interface{}{}
+	for key, value := range i.Fields.Unknowns {
+		if text, ok := value.(richText); ok && j.cloud {
+			unknowns[key] = MrkdwnToADF(string(text))
+		} else if ok {
+			unknowns[key] = MrkdwnToWiki(string(text))
+		} else {
+			unknowns[key] = value
+		}
+	}
+	fields.Unknowns = unknowns
+
+	if !j.cloud {
+		fields.Description = MrkdwnToWiki(fields.Description)
+		return j.client.Issue.Create(&jira.Issue{Fields: &fields})
+	}
+
+	fields.Unknowns["description"] = MrkdwnToADF(fields.Description)
+	fields.Description = ""
+
+	req, err := j.client.NewRequest("POST", "rest/api/3/issue", &jira.Issue{Fields: &fields})
+	if err != nil {
+		return nil, nil, err
+	}
+	issue := new(jira.Issue)
+	resp, err := j.client.Do(req, issue)
+	return issue, resp, err
+}
+
+// SetServiceReporter sets who is the reporter when the Slack user has no JIRA account
+func (j *JiraClient) SetServiceReporter(name string) {
+	j.serviceReporter = name
+}
+
+// setReporter uses the JIRA user matching the Slack user, or the service
+// reporter. Who asked in Slack is always in the description's Slack context.
+func (j *JiraClient) setReporter(issue *jira.Issue, issueRequest *TicketRequest) {
+	reporter := issueRequest.Reporter
+	if reporter == nil {
+		if j.serviceReporter == "" {
+			// JIRA will make whoever we're authenticated as the reporter
+			return
+		}
+		reporter = &BackendUser{Name: j.serviceReporter}
+	}
+
+	if reporter.AccountID != "" {
+		if issue.Fields.Unknowns == nil {
+			issue.Fields.Unknowns = map[string]interface{}{}
+		}
+		issue.Fields.Unknowns["reporter"] = map[string]string{"accountId": reporter.AccountID}
+	} else {
+		issue.Fields.Reporter = &jira.User{Name: reporter.Name}
+	}
+}
+
+type jiraSearchUser struct {
+	Name         string `json:"name"`
+	AccountID    string `json:"accountId"`
+	EmailAddress string `json:"emailAddress"`
+	Active       bool   `json:"active"`
+}
+
+// FindUserByEmail searches JIRA users, sending both the Server (username) and
+// Cloud (query) parameters so it works against either.
+func (j *JiraClient) FindUserByEmail(email string) (*BackendUser, error) {
+	params := url.Values{"username": {email}, "query": {email}}
+	req, err := j.client.NewRequest("GET", "rest/api/2/user/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var users []jiraSearchUser
+	if _, err := j.client.Do(req, &users); err != nil {
+		return nil, fmt.Errorf("Unable to search JIRA users: %v", err)
+	}
+
+	for _, user := range users {
+		// Cloud hides email addresses unless the user allows it, and a search
+		// result without one could be anybody
+		if user.EmailAddress != "" && strings.ToLower(user.EmailAddress) == strings.ToLower(email) {
+			return &BackendUser{Name: user.Name, AccountID: user.AccountID}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (j *JiraClient) CreateTicket(issueRequest *TicketRequest) (*Ticket, error) {
+	issue, skipped, err := j.CreateIssue(issueRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	// Assigned separately, so an assignee JIRA doesn't know only leaves it unassigned
+	assignee := issueRequest.AssigneeUser
+	if assignee == nil && issueRequest.Assignee != "" {
+		assignee = &BackendUser{Name: issueRequest.Assignee}
+	}
+	if assignee != nil {
+		if err := j.AssignTicket(issueRequest.ProjectKey, issue.Key, assignee); err != nil {
+			log.Printf("Unable to assign %s to %s, leaving it unassigned: %v\n", issue.Key, firstNonEmpty(assignee.Name, assignee.AccountID), err)
+		}
+	}
+
+	return &Ticket{
+		Key:     issue.Key,
+		URL:     j.GetTicketURL(issueRequest.ProjectKey, issue.Key),
+		Summary: issueRequest.Summary,
+		Skipped: skipped,
+	}, nil
+}
+
+func (j *JiraClient) GetTicket(projectKey string, key string) (*Ticket, error) {
+	issue, _, err := j.client.Issue.Get(key, nil)
 	if err != nil {
-		bodyBytes, _ := ioutil.ReadAll(resp.Body)
-		bodyString := string(bodyBytes)
-		return nil, fmt.Errorf(bodyString)
+		return nil, err
 	}
 
-	return issue, nil
+	ticket := &Ticket{Key: issue.Key, URL: j.GetTicketURL(projectKey, issue.Key)}
+	if issue.Fields != nil {
+		ticket.Summary = issue.Fields.Summary
+		if issue.Fields.Status != nil {
+			ticket.Status = issue.Fields.Status.Name
+		}
+	}
+	return ticket, nil
 }
 
 func (j *JiraClient) getComponentsForRequest(project *jira.Project, issueRequest *TicketRequest) ([]*jira.Component, error) {
@@ -74,7 +277,32 @@ func (j *JiraClient) getComponentsForRequest(project *jira.Project, issueRequest
 	return components, nil
 }
 
-func (j *JiraClient) GetTicketURL(key string) string {
+// getIssueTypeForRequest uses the configured issue type if the project has it,
+// otherwise the project default (the first issue type JIRA lists that isn't a
+// subtask, since those need a parent)
+func (j *JiraClient) getIssueTypeForRequest(project *jira.Project, issueRequest *TicketRequest) (string, error) {
+	defaultType := ""
+	for _, issueType := range project.IssueTypes {
+		if issueType.Subtask {
+			continue
+		}
+		if issueRequest.IssueType != "" && strings.ToLower(issueType.Name) == strings.ToLower(issueRequest.IssueType) {
+			return issueType.Name, nil
+		}
+		if defaultType == "" {
+			defaultType = issueType.Name
+		}
+	}
+	if defaultType == "" {
+		return "", fmt.Errorf("JIRA project `%s` has no issue types", issueRequest.ProjectKey)
+	}
+	if issueRequest.IssueType != "" {
+		log.Printf("Issue type `%s` is not in JIRA project `%s`, using the default\n", issueRequest.IssueType, issueRequest.ProjectKey)
+	}
+	return defaultType, nil
+}
+
+func (j *JiraClient) GetTicketURL(projectKey string, key string) string {
 	if j.publicEndpoint != "" {
 		return fmt.Sprintf("%s/browse/%s", j.publicEndpoint, key)
 	} else {
@@ -82,8 +310,190 @@ func (j *JiraClient) GetTicketURL(key string) string {
 	}
 }
 
-func (j *JiraClient) GetComponents(projectKey string) ([]jira.ProjectComponent, error) {
+func (j *JiraClient) GetComponents(projectKey string) ([]string, error) {
+	project, _, err := j.client.Project.Get(projectKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var components []string
+	for _, component := range project.Components {
+		components = append(components, component.Name)
+	}
+	return components, nil
+}
+
+func (j *JiraClient) GetIssueTypes(projectKey string) ([]string, error) {
 	project, _, err := j.client.Project.Get(projectKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var issueTypes []string
+	for _, issueType := range project.IssueTypes {
+		if !issueType.Subtask {
+			issueTypes = append(issueTypes, issueType.Name)
+		}
+	}
+	return issueTypes, nil
+}
+
+type jiraPermissions struct {
+	Permissions map[string]struct {
+		HavePermission bool `json:"havePermission"`
+	} `json:"permissions"`
+}
+
+// ValidateProject makes sure the project exists and that we're allowed to create issues in it
+func (j *JiraClient) ValidateProject(projectKey string) error {
+	if _, resp, err := j.client.Project.Get(projectKey); err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("JIRA project `%s` was not found", projectKey)
+		}
+		return fmt.Errorf("Unable to check JIRA project `%s`: %v", projectKey, err)
+	}
+
+	// Cloud refuses to list every permission, so only ask about the one we need
+	params := url.Values{"projectKey": {projectKey}, "permissions": {"CREATE_ISSUES"}}
+	req, err := j.client.NewRequest("GET", "rest/api/2/mypermissions?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	permissions := jiraPermissions{}
+	if _, err := j.client.Do(req, &permissions); err != nil {
+		return fmt.Errorf("Unable to check permissions on JIRA project `%s`: %v", projectKey, err)
+	}
+	if !permissions.Permissions["CREATE_ISSUES"].HavePermission {
+		return fmt.Errorf("I am not allowed to create issues in JIRA project `%s`", projectKey)
+	}
+	return nil
+}
 
-	return project.Components, err
+func (j *JiraClient) ListProjects() ([]string, error) {
+	list, _, err := j.client.Project.GetList()
+	if err != nil {
+		return nil, err
+	}
+
+	var projects []string
+	for _, project := range *list {
+		projects = append(projects, project.Key)
+	}
+	return projects, nil
+}
+
+type jiraRemoteLink struct {
+	Object jiraRemoteLinkObject `json:"object"`
+}
+
+type jiraRemoteLinkObject struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
+
+func (j *JiraClient) AddRemoteLink(ticket *Ticket, url string, title string) error {
+	link := jiraRemoteLink{Object: jiraRemoteLinkObject{URL: url, Title: title}}
+	req, err := j.client.NewRequest("POST", fmt.Sprintf("rest/api/2/issue/%s/remotelink", ticket.Key), link)
+	if err != nil {
+		return err
+	}
+	_, err = j.client.Do(req, nil)
+	return err
+}
+
+func (j *JiraClient) AddComment(projectKey string, key string, body string) error {
+	_, _, err := j.client.Issue.AddComment(key, &jira.Comment{Body: MrkdwnToWiki(body)})
+	return err
+}
+
+// Myself is the account slack-ask is authenticated as, which is who adds the
+// comments mirrored from Slack
+func (j *JiraClient) Myself() (*BackendUser, error) {
+	j.myselfLock.Lock()
+	defer j.myselfLock.Unlock()
+	if j.myself != nil {
+		return j.myself, nil
+	}
+
+	req, err := j.client.NewRequest("GET", "rest/api/2/myself", nil)
+	if err != nil {
+		return nil, err
+	}
+	user := jiraSearchUser{}
+	if _, err := j.client.Do(req, &user); err != nil {
+		return nil, err
+	}
+	j.myself = &BackendUser{Name: user.Name, AccountID: user.AccountID}
+	return j.myself, nil
+}
+
+func (j *JiraClient) AssignTicket(projectKey string, key string, user *BackendUser) error {
+	assignee := map[string]string{"name": user.Name}
+	if user.AccountID != "" {
+		assignee = map[string]string{"accountId": user.AccountID}
+	}
+
+	req, err := j.client.NewRequest("PUT", fmt.Sprintf("rest/api/2/issue/%s/assignee", key), assignee)
+	if err != nil {
+		return err
+	}
+	_, err = j.client.Do(req, nil)
+	return err
+}
+
+type jiraTransitions struct {
+	Transitions []struct {
+		ID string `json:"id"`
+		To struct {
+			Name           string `json:"name"`
+			StatusCategory struct {
+				Key string `json:"key"`
+			} `json:"statusCategory"`
+		} `json:"to"`
+	} `json:"transitions"`
+}
+
+// ResolveTicket takes the first transition into a done status, since every
+// workflow names them differently
+func (j *JiraClient) ResolveTicket(projectKey string, key string) error {
+	req, err := j.client.NewRequest("GET", fmt.Sprintf("rest/api/2/issue/%s/transitions", key), nil)
+	if err != nil {
+		return err
+	}
+	transitions := jiraTransitions{}
+	if _, err := j.client.Do(req, &transitions); err != nil {
+		return err
+	}
+
+	for _, transition := range transitions.Transitions {
+		if transition.To.StatusCategory.Key == "done" {
+			_, err := j.client.Issue.DoTransition(key, transition.ID)
+			return err
+		}
+	}
+	return fmt.Errorf("%s has no transition to a done status", key)
+}
+
+func (j *JiraClient) EscalateTicket(projectKey string, key string, priority storage.PriorityMapping) error {
+	update := map[string]interface{}{}
+	if len(priority.Labels) > 0 {
+		var labels []map[string]string
+		for _, label := range priority.Labels {
+			labels = append(labels, map[string]string{"add": label})
+		}
+		update["update"] = map[string]interface{}{"labels": labels}
+	}
+	if priority.Priority != "" {
+		update["fields"] = map[string]interface{}{"priority": map[string]string{"name": priority.Priority}}
+	}
+	if len(update) == 0 {
+		return fmt.Errorf("There is no priority or labels to escalate with, set them with `/ask config priority %s`", ESCALATE_PRIORITY)
+	}
+
+	req, err := j.client.NewRequest("PUT", fmt.Sprintf("rest/api/2/issue/%s", key), update)
+	if err != nil {
+		return err
+	}
+	_, err = j.client.Do(req, nil)
+	return err
 }
@@ -0,0 +1,174 @@
+package asker
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JiraAuth is how we authenticate to JIRA. Mode is one of:
+//
+//	basic: Username and Password, for JIRA Server
+//	cloud: Username is the Atlassian account email, Password is an API token
+//	token: Token is a JIRA Server personal access token
+//	oauth: OAuth 1.0a with ConsumerKey, the RSA PrivateKeyFile and an AccessToken
+type JiraAuth struct {
+	Mode           string
+	Username       string
+	Password       string
+	Token          string
+	ConsumerKey    string
+	PrivateKeyFile string
+	AccessToken    string
+}
+
+// httpClient returns the client go-jira should use, or nil for basic auth
+// which go-jira handles itself
+func (auth JiraAuth) httpClient() (*http.Client, error) {
+	switch auth.Mode {
+	case "", "basic", "cloud":
+		return nil, nil
+	case "token":
+		if auth.Token == "" {
+			return nil, fmt.Errorf("JIRA token authentication needs a personal access token (--jiratoken)")
+		}
+		return &http.Client{Transport: &bearerTransport{token: auth.Token}}, nil
+	case "oauth":
+		if auth.ConsumerKey == "" || auth.PrivateKeyFile == "" || auth.AccessToken == "" {
+			return nil, fmt.Errorf("JIRA OAuth needs a consumer key, private key and access token (--jiraconsumerkey, --jiraprivatekey, --jiratoken)")
+		}
+		key, err := loadPrivateKey(auth.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Client{Transport: &oauth1Transport{consumerKey: auth.ConsumerKey, token: auth.AccessToken, key: key}}, nil
+	default:
+		return nil, fmt.Errorf("Unknown JIRA authentication mode `%s`, use basic, cloud, token or oauth", auth.Mode)
+	}
+}
+
+type bearerTransport struct {
+	token string
+}
+
+func (t *bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req2 := cloneRequest(req)
+	req2.Header.Set("Authorization", "Bearer "+t.token)
+	return http.DefaultTransport.RoundTrip(req2)
+}
+
+// oauth1Transport signs requests with RSA-SHA1, which is the only signature
+// method JIRA application links support
+type oauth1Transport struct {
+	consumerKey string
+	token       string
+	key         *rsa.PrivateKey
+}
+
+func (t *oauth1Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req2 := cloneRequest(req)
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	oauthParams := map[string]string{
+		"oauth_consumer_key":     t.consumerKey,
+		"oauth_nonce":            hex.EncodeToString(nonce),
+		"oauth_signature_method": "RSA-SHA1",
+		"oauth_timestamp":        strconv.FormatInt(time.Now().Unix(), 10),
+		"oauth_token":            t.token,
+		"oauth_version":          "1.0",
+	}
+
+	signature, err := t.sign(req2, oauthParams)
+	if err != nil {
+		return nil, err
+	}
+	oauthParams["oauth_signature"] = signature
+
+	var header []string
+	for key, value := range oauthParams {
+		header = append(header, fmt.Sprintf(`%s="%s"`, key, oauthEscape(value)))
+	}
+	sort.Strings(header)
+	req2.Header.Set("Authorization", "OAuth "+strings.Join(header, ", "))
+
+	return http.DefaultTransport.RoundTrip(req2)
+}
+
+func (t *oauth1Transport) sign(req *http.Request, oauthParams map[string]string) (string, error) {
+	var params []string
+	for key, value := range oauthParams {
+		params = append(params, oauthEscape(key)+"="+oauthEscape(value))
+	}
+	for key, values := range req.URL.Query() {
+		for _, value := range values {
+			params = append(params, oauthEscape(key)+"="+oauthEscape(value))
+		}
+	}
+	sort.Strings(params)
+
+	baseURL := fmt.Sprintf("%s://%s%s", strings.ToLower(req.URL.Scheme), strings.ToLower(req.URL.Host), req.URL.EscapedPath())
+	base := strings.Join([]string{req.Method, oauthEscape(baseURL), oauthEscape(strings.Join(params, "&"))}, "&")
+
+	hashed := sha1.Sum([]byte(base))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, t.key, crypto.SHA1, hashed[:])
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// oauthEscape percent encodes as RFC 3986 requires, which isn't quite what url.QueryEscape does
+func oauthEscape(value string) string {
+	return strings.Replace(strings.Replace(url.QueryEscape(value), "+", "%20", -1), "%7E", "~", -1)
+}
+
+func loadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("No PEM encoded key found in %s", path)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse the private key in %s: %v", path, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("The private key in %s is not an RSA key", path)
+	}
+	return key, nil
+}
+
+// cloneRequest copies the request and its headers, since RoundTrippers must not modify the original
+func cloneRequest(req *http.Request) *http.Request {
+	req2 := new(http.Request)
+	*req2 = *req
+	req2.Header = make(http.Header, len(req.Header))
+	for key, values := range req.Header {
+		req2.Header[key] = append([]string(nil), values...)
+	}
+	return req2
+}
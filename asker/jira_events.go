@@ -0,0 +1,128 @@
+package asker
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// MAX_COMMENT_LENGTH keeps long JIRA comments from flooding the thread
+const MAX_COMMENT_LENGTH = 1000
+
+type jiraWebhookUser struct {
+	Name        string `json:"name"`
+	AccountID   string `json:"accountId"`
+	DisplayName string `json:"displayName"`
+}
+
+// is whether this is the given JIRA user, by account ID on Cloud or name on Server
+func (user *jiraWebhookUser) is(other *BackendUser) bool {
+	if other == nil {
+		return false
+	}
+	if user.AccountID != "" || other.AccountID != "" {
+		return user.AccountID == other.AccountID
+	}
+	return user.Name != "" && user.Name == other.Name
+}
+
+type JiraWebhookEvent struct {
+	WebhookEvent string `json:"webhookEvent"`
+	Issue        struct {
+		Key string `json:"key"`
+	} `json:"issue"`
+	User    jiraWebhookUser `json:"user"`
+	Comment struct {
+		Body   string          `json:"body"`
+		Author jiraWebhookUser `json:"author"`
+	} `json:"comment"`
+	Changelog struct {
+		Items []struct {
+			Field      string `json:"field"`
+			FromString string `json:"fromString"`
+			ToString   string `json:"toString"`
+		} `json:"items"`
+	} `json:"changelog"`
+}
+
+// replyText is what gets posted to the ask's thread, empty if nothing relevant
+// happened. Everything from JIRA is escaped so it can't mention anyone.
+// mirrorAccount is who adds the comments mirrored from Slack, if known.
+func (event *JiraWebhookEvent) replyText(mirrorAccount *BackendUser) string {
+	switch event.WebhookEvent {
+	case "comment_created":
+		if event.Comment.Author.is(mirrorAccount) && strings.HasPrefix(event.Comment.Body, SLACK_COMMENT_PREFIX) {
+			// This came from the thread in the first place, don't echo it back
+			return ""
+		}
+		body := []rune(strings.TrimSpace(event.Comment.Body))
+		if len(body) > MAX_COMMENT_LENGTH {
+			body = append(body[:MAX_COMMENT_LENGTH], '…')
+		}
+		return fmt.Sprintf("*%s* commented on %s:\n%s", escapeSlack(firstNonEmpty(event.Comment.Author.DisplayName, event.Comment.Author.Name)), escapeSlack(event.Issue.Key), escapeSlack(string(body)))
+	case "jira:issue_updated":
+		for _, item := range event.Changelog.Items {
+			if item.Field == "status" {
+				return fmt.Sprintf("*%s* moved %s from %s to *%s*", escapeSlack(firstNonEmpty(event.User.DisplayName, event.User.Name)), escapeSlack(event.Issue.Key), escapeSlack(item.FromString), escapeSlack(item.ToString))
+			}
+		}
+	}
+	return ""
+}
+
+// JiraEventHandler receives JIRA webhooks and posts comments and status
+// changes into the thread of the ask that created the issue. It is only
+// registered with --jirawebhooksecret, but refuses everything without one too.
+func (a *Asker) JiraEventHandler(w http.ResponseWriter, r *http.Request) {
+	if a.JiraWebhookSecret == "" || !hmac.Equal([]byte(r.URL.Query().Get("secret")), []byte(a.JiraWebhookSecret)) {
+		log.Printf("Rejecting JIRA webhook with an invalid secret\n")
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprintf(w, "Bad request")
+		return
+	}
+
+	event := JiraWebhookEvent{}
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		log.Printf("Unable to decode JIRA webhook: %+v\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Bad request")
+		return
+	}
+
+	var mirrorAccount *BackendUser
+	if event.WebhookEvent == "comment_created" {
+		if backend, err := a.GetBackend(DEFAULT_BACKEND); err == nil {
+			if client, ok := backend.(*JiraClient); ok {
+				if mirrorAccount, err = client.Myself(); err != nil {
+					log.Printf("Unable to look up the JIRA account mirrored comments come from: %v\n", err)
+				}
+			}
+		}
+	}
+
+	text := event.replyText(mirrorAccount)
+	if text == "" || event.Issue.Key == "" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	db := MgoDBFromRequest(r)
+	message, err := db.GetAskMessage(DEFAULT_BACKEND, event.Issue.Key)
+	if err != nil {
+		// Not every issue came from an ask
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if _, err := chatPostMessage(a.teamToken(db, message.TeamID), message.ChannelID, message.Timestamp, SlackResponseResult{Text: text}); err != nil {
+		log.Printf("Unable to post JIRA update for %s to Slack: %v\n", event.Issue.Key, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "Unable to post to Slack")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,67 @@
+package asker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestJiraReplyText(t *testing.T) {
+	bot := &BackendUser{Name: "slack-ask"}
+	comment := func(author jiraWebhookUser, body string) *JiraWebhookEvent {
+		event := &JiraWebhookEvent{WebhookEvent: "comment_created"}
+		event.Issue.Key = "PROJ-1"
+		event.Comment.Author = author
+		event.Comment.Body = body
+		return event
+	}
+
+	tests := []struct {
+		name    string
+		event   *JiraWebhookEvent
+		account *BackendUser
+		want    string
+	}{
+		{"comment", comment(jiraWebhookUser{Name: "jane", DisplayName: "Jane"}, "Looking"), bot, "*Jane* commented on PROJ-1:\nLooking"},
+		{"mirrored", comment(jiraWebhookUser{Name: "slack-ask"}, SLACK_COMMENT_PREFIX+"Jane:\nThanks"), bot, ""},
+		{"mirrored on Cloud", comment(jiraWebhookUser{AccountID: "5b10"}, SLACK_COMMENT_PREFIX+"Jane:\nThanks"), &BackendUser{AccountID: "5b10"}, ""},
+		{"looks mirrored", comment(jiraWebhookUser{Name: "jane", DisplayName: "Jane"}, SLACK_COMMENT_PREFIX+"me"), bot, "*Jane* commented on PROJ-1:\n" + SLACK_COMMENT_PREFIX + "me"},
+		{"bot without the prefix", comment(jiraWebhookUser{Name: "slack-ask", DisplayName: "Ask"}, "Automated"), bot, "*Ask* commented on PROJ-1:\nAutomated"},
+		{"account unknown", comment(jiraWebhookUser{Name: "slack-ask", DisplayName: "Ask"}, SLACK_COMMENT_PREFIX+"x"), nil, "*Ask* commented on PROJ-1:\n" + SLACK_COMMENT_PREFIX + "x"},
+		{"no mentions", comment(jiraWebhookUser{Name: "jane"}, "<!channel> look"), bot, "*jane* commented on PROJ-1:\n&lt;!channel&gt; look"},
+		{"other event", &JiraWebhookEvent{WebhookEvent: "jira:issue_created"}, bot, ""},
+	}
+	for _, test := range tests {
+		if got := test.event.replyText(test.account); got != test.want {
+			t.Errorf("%s: replyText = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestJiraMyself(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/2/myself" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		requests++
+		json.NewEncoder(w).Encode(map[string]string{"name": "slack-ask", "accountId": "5b10"})
+	}))
+	defer server.Close()
+
+	client, err := (&Asker{}).NewJira(server.URL, JiraAuth{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		user, err := client.Myself()
+		if err != nil || user.Name != "slack-ask" || user.AccountID != "5b10" {
+			t.Errorf("Myself returned %+v, %v", user, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("Looked up the account %d times, want once", requests)
+	}
+}
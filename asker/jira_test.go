@@ -0,0 +1,96 @@
+package asker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// jiraStandIn answers project lookups and creates PROJ-1, refusing whichever
+// fields are in refused the way JIRA does when they aren't on the create screen
+func jiraStandIn(t *testing.T, refused map[string]string, created *[]map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/rest/api/2/project/PROJ":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"key":        "PROJ",
+				"issueTypes": []map[string]interface{}{{"name": "Sub-task", "subtask": true}, {"name": "Task"}},
+			})
+		case r.Method == "POST" && strings.TrimSuffix(r.URL.Path, "/") == "/rest/api/2/issue":
+			issue := struct {
+				Fields map[string]interface{} `json:"fields"`
+			}{}
+			if err := json.NewDecoder(r.Body).Decode(&issue); err != nil {
+				t.Errorf("Unable to decode the issue: %v", err)
+			}
+			*created = append(*created, issue.Fields)
+
+			errors := map[string]string{}
+			for field, message := range refused {
+				if _, ok := issue.Fields[field]; ok {
+					errors[field] = message
+				}
+			}
+			if len(errors) > 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]interface{}{"errorMessages": []string{}, "errors": errors})
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]string{"id": "10001", "key": "PROJ-1"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestJiraCreateTicketRefusedFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		refused map[string]string
+		skipped []string
+		creates int
+		err     string
+	}{
+		{"accepted", nil, nil, 1, ""},
+		{"no such priority", map[string]string{"priority": "Priority name 'Highest' is not valid"}, []string{"priority `Highest`"}, 2, ""},
+		{"not on the create screen", map[string]string{
+			"priority": "Field 'priority' cannot be set.",
+			"labels":   "Field 'labels' cannot be set.",
+		}, []string{"priority `Highest`", "labels `urgent paged`"}, 2, ""},
+		{"something else", map[string]string{"customfield_1": "Team is required."}, nil, 1, "Team is required."},
+	}
+	for _, test := range tests {
+		var created []map[string]interface{}
+		server := jiraStandIn(t, test.refused, &created)
+
+		client, err := (&Asker{}).NewJira(server.URL, JiraAuth{}, "https://jira.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ticket, err := client.CreateTicket(&TicketRequest{
+			ProjectKey: "PROJ",
+			Summary:    "Help",
+			Priority:   "Highest",
+			Labels:     []string{"urgent", "paged"},
+			Fields:     map[string]interface{}{"customfield_1": "core"},
+		})
+		server.Close()
+
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: CreateTicket returned %+v, %v, want an error containing %q", test.name, ticket, err, test.err)
+			}
+		} else if err != nil {
+			t.Errorf("%s: CreateTicket returned %v", test.name, err)
+		} else if ticket.Key != "PROJ-1" || !reflect.DeepEqual(ticket.Skipped, test.skipped) {
+			t.Errorf("%s: CreateTicket returned %+v, want PROJ-1 without %v", test.name, ticket, test.skipped)
+		}
+		if len(created) != test.creates {
+			t.Errorf("%s: tried to create the issue %d times, want %d", test.name, len(created), test.creates)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package asker
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jshirley/slack-ask/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// LocalTracker keeps asks in Mongo, for teams that don't want an external tracker
+type LocalTracker struct {
+	publicEndpoint string
+	session        storage.Session
+
+	// teamID and enterpriseID are whose questions GetTicket looks at, see ForWorkspace
+	teamID       string
+	enterpriseID string
+}
+
+func (ask *Asker) NewLocalTracker(publicEndpoint string) *LocalTracker {
+	return &LocalTracker{
+		publicEndpoint: strings.TrimRight(publicEndpoint, "/"),
+		session:        ask.storage,
+	}
+}
+
+func (l *LocalTracker) ticketFromQuestion(question *storage.Question) *Ticket {
+	return &Ticket{
+		Key:     question.Key,
+		URL:     l.questionURL(question),
+		Summary: question.Summary,
+		Status:  question.Status,
+	}
+}
+
+func (l *LocalTracker) CreateTicket(issueRequest *TicketRequest) (*Ticket, error) {
+	dbSession := l.session.Copy()
+	defer dbSession.Close()
+
+	question := &storage.Question{
+		ChannelID:   issueRequest.ChannelID,
+		Project:     issueRequest.ProjectKey,
+		Summary:     issueRequest.Summary,
+		Description: issueRequest.Description,
+		Components:  issueRequest.Components,
+		Status:      "Open",
+		Reporter:    issueRequest.Username,
+		Assignee:    issueRequest.Assignee,
+		Created:     time.Now().Unix(),
+	}
+	db := dbSession.DB("slack-ask")
+	if issueRequest.Command != nil {
+		db = db.ForWorkspace(issueRequest.Command.TeamID, issueRequest.Command.EnterpriseID)
+	}
+	if err := db.CreateQuestion(question); err != nil {
+		log.Printf("Unable to store question in `%s`: %s\n", issueRequest.ProjectKey, err)
+		return nil, err
+	}
+
+	return l.ticketFromQuestion(question), nil
+}
+
+// ForWorkspace returns the tracker for looking up a single workspace's questions
+func (l *LocalTracker) ForWorkspace(teamID string, enterpriseID string) *LocalTracker {
+	scoped := *l
+	scoped.teamID, scoped.enterpriseID = teamID, enterpriseID
+	return &scoped
+}
+
+// GetTicket only looks within the tracker's workspace, since every workspace
+// numbers its questions from 1
+func (l *LocalTracker) GetTicket(project string, key string) (*Ticket, error) {
+	if l.teamID == "" && l.enterpriseID == "" {
+		return nil, fmt.Errorf("Local questions can only be looked up within a workspace")
+	}
+
+	dbSession := l.session.Copy()
+	defer dbSession.Close()
+
+	question, err := dbSession.DB("slack-ask").ForWorkspace(l.teamID, l.enterpriseID).GetQuestion(project, key)
+	if err != nil {
+		return nil, err
+	}
+	return l.ticketFromQuestion(question), nil
+}
+
+func (l *LocalTracker) GetTicketURL(project string, key string) string {
+	return fmt.Sprintf("%s/questions/%s/%s", l.publicEndpoint, project, key)
+}
+
+// questionURL includes the workspace, since each has its own question numbers
+func (l *LocalTracker) questionURL(question *storage.Question) string {
+	if question.Workspace == "" {
+		return l.GetTicketURL(question.Project, question.Key)
+	}
+	return fmt.Sprintf("%s/questions/%s/%s/%s", l.publicEndpoint, question.Workspace, question.Project, question.Key)
+}
+
+// GetComponents returns nothing, any component is fine for local questions
+func (l *LocalTracker) GetComponents(project string) ([]string, error) {
+	return []string{}, nil
+}
+
+var questionTemplate = template.Must(template.New("question").Parse(`<!DOCTYPE html>
+<html>
+<head><title>{{.Key}}: {{.Summary}}</title></head>
+<body>
+<h1>{{.Key}}: {{.Summary}}</h1>
+<p><strong>Status:</strong> {{.Status}}</p>
+<p><strong>Asked by:</strong> {{.Reporter}}</p>
+{{if .Assignee}}<p><strong>Assigned to:</strong> {{.Assignee}}</p>{{end}}
+{{if .Components}}<p><strong>Components:</strong> {{range $i, $c := .Components}}{{if $i}}, {{end}}{{$c}}{{end}}</p>{{end}}
+<pre>{{.Description}}</pre>
+</body>
+</html>
+`))
+
+func (a *Asker) QuestionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	// Links without a workspace are from before questions were per workspace
+	workspace := firstNonEmpty(vars["workspace"], a.legacyWorkspace)
+	question, err := MgoDBFromRequest(r).ForWorkspace(workspace, "").GetQuestion(vars["project"], vars["key"])
+	if workspace == "" || err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "No question found for %s", vars["key"])
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if err := questionTemplate.Execute(w, question); err != nil {
+		log.Printf("Unable to render question %s: %+v\n", question.Key, err)
+	}
+}
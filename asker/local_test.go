@@ -0,0 +1,34 @@
+package asker
+
+import (
+	"testing"
+
+	"github.com/jshirley/slack-ask/storage"
+)
+
+func TestLocalTrackerWorkspaces(t *testing.T) {
+	tracker := (&Asker{}).NewLocalTracker("https://ask.example.com/")
+
+	// Without a workspace there's no telling whose ASK-1 it is
+	if _, err := tracker.GetTicket("ASK", "ASK-1"); err == nil {
+		t.Errorf("Looked up a question without a workspace")
+	}
+
+	scoped := tracker.ForWorkspace("T123", "E123")
+	if scoped.teamID != "T123" || scoped.enterpriseID != "E123" || tracker.teamID != "" {
+		t.Errorf("ForWorkspace scoped %+v and left %+v", scoped, tracker)
+	}
+
+	tests := []struct {
+		question storage.Question
+		want     string
+	}{
+		{storage.Question{Workspace: "E123", Project: "ASK", Key: "ASK-1"}, "https://ask.example.com/questions/E123/ASK/ASK-1"},
+		{storage.Question{Project: "ASK", Key: "ASK-1"}, "https://ask.example.com/questions/ASK/ASK-1"},
+	}
+	for _, test := range tests {
+		if got := tracker.questionURL(&test.question); got != test.want {
+			t.Errorf("questionURL(%+v) = %q, want %q", test.question, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package asker
+
+// Converts the Slack mrkdwn people type into the dialog into something JIRA
+// renders: wiki markup for JIRA Server, Atlassian Document Format for Cloud.
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+type markupSpan struct {
+	Text   string
+	Href   string
+	Bold   bool
+	Italic bool
+	Strike bool
+	Code   bool
+}
+
+type markupBlock struct {
+	Kind  string // paragraph, code, quote or bullet
+	Lines [][]markupSpan
+	Code  string
+}
+
+var (
+	codeFencePattern = regexp.MustCompile("(?s)```(.*?)```")
+	// Like Slack, emphasis doesn't start or end with a space or touch a word
+	// on the outside, so `2 * 3 * 4` and `snake_case_name` stay as they are
+	// Modal answers aren't escaped by Slack, so only what Slack itself would
+	// send is taken as a link or mention, and `x <5 and y> 3` stays as it is.
+	// Bare URLs are links too, so they're never escaped.
+	inlinePattern = regexp.MustCompile("<((?:https?://|mailto:|[@#!])[^<>\n]*)>|`([^`\n]+)`|" +
+		"\\B\\*([^*\\s](?:[^*\n]*[^*\\s])?)\\*\\B|" +
+		"\\b_([^_\\s](?:[^_\n]*[^_\\s])?)_\\b|" +
+		"\\B~([^~\\s](?:[^~\n]*[^~\\s])?)~\\B|" +
+		"(https?://[^\\s<>]*[^\\s<>.,;:!?)\\]])")
+	bulletPattern = regexp.MustCompile(`^\s*(?:•|-|\*)\s+`)
+	quotePattern  = regexp.MustCompile(`^(?:&gt;|>)\s?`)
+	slackEscapes  = strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">")
+)
+
+// parseMrkdwn splits Slack text into blocks of inline spans
+func parseMrkdwn(text string) []markupBlock {
+	var blocks []markupBlock
+
+	last := 0
+	for _, match := range codeFencePattern.FindAllStringSubmatchIndex(text, -1) {
+		blocks = append(blocks, parseTextBlocks(text[last:match[0]])...)
+		code := strings.Trim(text[match[2]:match[3]], "\n")
+		blocks = append(blocks, markupBlock{Kind: "code", Code: slackEscapes.Replace(code)})
+		last = match[1]
+	}
+	return append(blocks, parseTextBlocks(text[last:])...)
+}
+
+func parseTextBlocks(text string) []markupBlock {
+	var blocks []markupBlock
+	var current *markupBlock
+
+	for _, line := range strings.Split(text, "\n") {
+		kind := "paragraph"
+		if strings.TrimSpace(line) == "" {
+			current = nil
+			continue
+		} else if quotePattern.MatchString(line) {
+			kind, line = "quote", quotePattern.ReplaceAllString(line, "")
+		} else if bulletPattern.MatchString(line) {
+			kind, line = "bullet", bulletPattern.ReplaceAllString(line, "")
+		}
+
+		if current == nil || current.Kind != kind {
+			blocks = append(blocks, markupBlock{Kind: kind})
+			current = &blocks[len(blocks)-1]
+		}
+		current.Lines = append(current.Lines, parseInline(line, markupSpan{}))
+	}
+	return blocks
+}
+
+// parseInline finds links, mentions, code and emphasis, with marks from
+// enclosing emphasis passed down in style
+func parseInline(text string, style markupSpan) []markupSpan {
+	var spans []markupSpan
+	plain := func(s string) {
+		if s != "" {
+			span := style
+			span.Text = slackEscapes.Replace(s)
+			spans = append(spans, span)
+		}
+	}
+
+	last := 0
+	for _, match := range inlinePattern.FindAllStringSubmatchIndex(text, -1) {
+		plain(text[last:match[0]])
+		last = match[1]
+
+		switch {
+		case match[2] >= 0:
+			spans = append(spans, parseAngleBrackets(text[match[2]:match[3]], style))
+		case match[4] >= 0:
+			span := style
+			span.Text, span.Code = slackEscapes.Replace(text[match[4]:match[5]]), true
+			spans = append(spans, span)
+		case match[6] >= 0:
+			inner := style
+			inner.Bold = true
+			spans = append(spans, parseInline(text[match[6]:match[7]], inner)...)
+		case match[8] >= 0:
+			inner := style
+			inner.Italic = true
+			spans = append(spans, parseInline(text[match[8]:match[9]], inner)...)
+		case match[10] >= 0:
+			inner := style
+			inner.Strike = true
+			spans = append(spans, parseInline(text[match[10]:match[11]], inner)...)
+		case match[12] >= 0:
+			span := style
+			span.Href = slackEscapes.Replace(text[match[12]:match[13]])
+			span.Text = span.Href
+			spans = append(spans, span)
+		}
+	}
+	plain(text[last:])
+
+	return spans
+}
+
+// parseAngleBrackets handles <url|label>, <@U123|name>, <#C123|channel> and <!here>
+func parseAngleBrackets(content string, style markupSpan) markupSpan {
+	span := style
+	target, label := content, ""
+	if i := strings.Index(content, "|"); i >= 0 {
+		target, label = content[:i], content[i+1:]
+	}
+
+	switch {
+	case strings.HasPrefix(target, "@"):
+		span.Text = "@" + firstNonEmpty(label, target[1:])
+	case strings.HasPrefix(target, "#"):
+		span.Text = "#" + firstNonEmpty(label, target[1:])
+	case strings.HasPrefix(target, "!subteam^"):
+		span.Text = firstNonEmpty(label, "@group")
+	case strings.HasPrefix(target, "!"):
+		span.Text = "@" + firstNonEmpty(label, target[1:])
+	default:
+		span.Href = slackEscapes.Replace(target)
+		span.Text = slackEscapes.Replace(firstNonEmpty(label, target))
+	}
+	return span
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// wikiEscapes stops text people typed from being read as wiki markup, like
+// table cells, links or !images!
+var wikiEscapes = strings.NewReplacer("{", "\\{", "}", "\\}", "[", "\\[", "]", "\\]", "|", "\\|", "!", "\\!")
+
+// wikiEffects only mark up text when they're at the edge of a word, like
+// -strikethrough- or +underline+, so they're left alone inside `acme-corp`
+const wikiEffects = "*_-+^~?"
+
+var codeEscapes = strings.NewReplacer("{", "\\{", "}", "\\}")
+
+func escapeWiki(text string) string {
+	runes := []rune(wikiEscapes.Replace(text))
+	var escaped []rune
+	for i, r := range runes {
+		if strings.ContainsRune(wikiEffects, r) && !(i > 0 && isWordRune(runes[i-1]) && i+1 < len(runes) && isWordRune(runes[i+1])) {
+			escaped = append(escaped, '\\')
+		}
+		escaped = append(escaped, r)
+	}
+	return string(escaped)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// MrkdwnToWiki converts Slack mrkdwn into JIRA wiki markup
+func MrkdwnToWiki(text string) string {
+	var rendered []string
+	for _, block := range parseMrkdwn(text) {
+		switch block.Kind {
+		case "code":
+			rendered = append(rendered, fmt.Sprintf("{code}\n%s\n{code}", block.Code))
+		case "quote":
+			rendered = append(rendered, fmt.Sprintf("{quote}\n%s\n{quote}", wikiLines(block.Lines, "")))
+		case "bullet":
+			rendered = append(rendered, wikiLines(block.Lines, "* "))
+		default:
+			rendered = append(rendered, wikiLines(block.Lines, ""))
+		}
+	}
+	return strings.Join(rendered, "\n\n")
+}
+
+func wikiLines(lines [][]markupSpan, prefix string) string {
+	var rendered []string
+	for _, line := range lines {
+		var text string
+		for _, span := range line {
+			text += wikiSpan(span)
+		}
+		rendered = append(rendered, prefix+text)
+	}
+	return strings.Join(rendered, "\n")
+}
+
+func wikiSpan(span markupSpan) string {
+	if span.Code {
+		return "{{" + codeEscapes.Replace(span.Text) + "}}"
+	}
+
+	text := escapeWiki(span.Text)
+	if span.Href != "" {
+		if span.Text == span.Href {
+			text = "[" + span.Href + "]"
+		} else {
+			text = "[" + text + "|" + span.Href + "]"
+		}
+	}
+	if span.Bold {
+		text = "*" + text + "*"
+	}
+	if span.Italic {
+		text = "_" + text + "_"
+	}
+	if span.Strike {
+		text = "-" + text + "-"
+	}
+	return text
+}
+
+type adfNode map[string]interface{}
+
+// MrkdwnToADF converts Slack mrkdwn into an Atlassian Document Format document
+func MrkdwnToADF(text string) adfNode {
+	content := []adfNode{}
+	for _, block := range parseMrkdwn(text) {
+		switch block.Kind {
+		case "code":
+			code := adfNode{"type": "codeBlock"}
+			if block.Code != "" {
+				code["content"] = []adfNode{adfNode{"type": "text", "text": block.Code}}
+			}
+			content = append(content, code)
+		case "quote":
+			content = append(content, adfNode{"type": "blockquote", "content": []adfNode{adfParagraph(block.Lines)}})
+		case "bullet":
+			var items []adfNode
+			for _, line := range block.Lines {
+				items = append(items, adfNode{"type": "listItem", "content": []adfNode{adfParagraph([][]markupSpan{line})}})
+			}
+			content = append(content, adfNode{"type": "bulletList", "content": items})
+		default:
+			content = append(content, adfParagraph(block.Lines))
+		}
+	}
+
+	return adfNode{"version": 1, "type": "doc", "content": content}
+}
+
+func adfParagraph(lines [][]markupSpan) adfNode {
+	content := []adfNode{}
+	for i, line := range lines {
+		if i > 0 {
+			content = append(content, adfNode{"type": "hardBreak"})
+		}
+		for _, span := range line {
+			if span.Text != "" {
+				content = append(content, adfText(span))
+			}
+		}
+	}
+	return adfNode{"type": "paragraph", "content": content}
+}
+
+func adfText(span markupSpan) adfNode {
+	var marks []adfNode
+	if span.Code {
+		marks = append(marks, adfNode{"type": "code"})
+	} else {
+		if span.Bold {
+			marks = append(marks, adfNode{"type": "strong"})
+		}
+		if span.Italic {
+			marks = append(marks, adfNode{"type": "em"})
+		}
+		if span.Strike {
+			marks = append(marks, adfNode{"type": "strike"})
+		}
+	}
+	if span.Href != "" {
+		marks = append(marks, adfNode{"type": "link", "attrs": adfNode{"href": span.Href}})
+	}
+
+	node := adfNode{"type": "text", "text": span.Text}
+	if len(marks) > 0 {
+		node["marks"] = marks
+	}
+	return node
+}
+
+// MrkdwnToPlain drops the formatting, for fields like the summary that are plain text
+func MrkdwnToPlain(text string) string {
+	var rendered []string
+	for _, block := range parseMrkdwn(text) {
+		if block.Kind == "code" {
+			rendered = append(rendered, block.Code)
+			continue
+		}
+		for _, line := range block.Lines {
+			var plain string
+			for _, span := range line {
+				plain += span.Text
+			}
+			rendered = append(rendered, plain)
+		}
+	}
+	return strings.Join(rendered, " ")
+}
@@ -0,0 +1,107 @@
+package asker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMrkdwnToWiki(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"*bold* and _italic_ and ~gone~", "*bold* and _italic_ and -gone-"},
+		{"*_both_*", "_*both*_"},
+		{"2 * 3 * 4", "2 \\* 3 \\* 4"},
+		{"snake_case_name and well-known", "snake_case_name and well-known"},
+		{"a | b - c + d -gone- +under+ ^up^", "a \\| b \\- c \\+ d \\-gone\\- \\+under\\+ \\^up\\^"},
+		{"is x <5 and y> 3?", "is x <5 and y> 3\\?"},
+		{"Channel: #team-support (https://acme-corp.slack.com/archives/C1)", "Channel: #team-support ([https://acme-corp.slack.com/archives/C1])"},
+		{"see https://acme-corp.example.com/a_b?c=d.", "see [https://acme-corp.example.com/a_b?c=d]."},
+		{"<mailto:help@acme-corp.com|help@acme-corp.com>", "[help@acme-corp.com|mailto:help@acme-corp.com]"},
+		{"{noformat} [link] !image.png!", "\\{noformat\\} \\[link\\] \\!image.png\\!"},
+		{"fish &amp; chips &lt;3", "fish & chips <3"},
+		{"`x := map[string]int{}`", "{{x := map[string]int\\{\\}}}"},
+		{"<https://example.com>", "[https://example.com]"},
+		{"<https://example.com|the docs>", "[the docs|https://example.com]"},
+		{"<https://example.com|a|b>", "[a\\|b|https://example.com]"},
+		{"<@U123|jane> and <#C123|general> and <!here>", "@jane and #general and @here"},
+		{"```\nfunc() {}\n```", "{code}\nfunc() {}\n{code}"},
+		{"&gt; quoted\n&gt; twice\nafter", "{quote}\nquoted\ntwice\n{quote}\n\nafter"},
+		{"• one\n- two", "* one\n* two"},
+		{"first\n\nsecond", "first\n\nsecond"},
+	}
+	for _, test := range tests {
+		if got := MrkdwnToWiki(test.text); got != test.want {
+			t.Errorf("MrkdwnToWiki(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestMrkdwnToADF(t *testing.T) {
+	doc := func(content ...adfNode) adfNode {
+		return adfNode{"version": 1, "type": "doc", "content": append([]adfNode{}, content...)}
+	}
+	paragraph := func(content ...adfNode) adfNode {
+		return adfNode{"type": "paragraph", "content": append([]adfNode{}, content...)}
+	}
+	text := func(s string, marks ...string) adfNode {
+		node := adfNode{"type": "text", "text": s}
+		var rendered []adfNode
+		for _, mark := range marks {
+			rendered = append(rendered, adfNode{"type": mark})
+		}
+		if len(rendered) > 0 {
+			node["marks"] = rendered
+		}
+		return node
+	}
+
+	tests := []struct {
+		text string
+		want adfNode
+	}{
+		{"", doc()},
+		{"*bold* 2 * 3", doc(paragraph(text("bold", "strong"), text(" 2 * 3")))},
+		{"_em_ `code`", doc(paragraph(text("em", "em"), text(" "), text("code", "code")))},
+		{"one\ntwo", doc(paragraph(text("one"), adfNode{"type": "hardBreak"}, text("two")))},
+		{"<https://example.com|docs>", doc(paragraph(adfNode{
+			"type":  "text",
+			"text":  "docs",
+			"marks": []adfNode{adfNode{"type": "link", "attrs": adfNode{"href": "https://example.com"}}},
+		}))},
+		{"https://acme-corp.example.com", doc(paragraph(adfNode{
+			"type":  "text",
+			"text":  "https://acme-corp.example.com",
+			"marks": []adfNode{adfNode{"type": "link", "attrs": adfNode{"href": "https://acme-corp.example.com"}}},
+		}))},
+		{"x <5 and y> 3", doc(paragraph(text("x <5 and y> 3")))},
+		{"```x```", doc(adfNode{"type": "codeBlock", "content": []adfNode{text("x")}})},
+		{"&gt; quoted", doc(adfNode{"type": "blockquote", "content": []adfNode{paragraph(text("quoted"))}})},
+		{"• one\n• two", doc(adfNode{"type": "bulletList", "content": []adfNode{
+			adfNode{"type": "listItem", "content": []adfNode{paragraph(text("one"))}},
+			adfNode{"type": "listItem", "content": []adfNode{paragraph(text("two"))}},
+		}})},
+	}
+	for _, test := range tests {
+		if got := MrkdwnToADF(test.text); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("MrkdwnToADF(%q) = %#v, want %#v", test.text, got, test.want)
+		}
+	}
+}
+
+func TestMrkdwnToPlain(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"*Help* with <https://example.com|the docs>", "Help with the docs"},
+		{"line one\nline two", "line one line two"},
+		{"2 * 3 * 4", "2 * 3 * 4"},
+	}
+	for _, test := range tests {
+		if got := MrkdwnToPlain(test.text); got != test.want {
+			t.Errorf("MrkdwnToPlain(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
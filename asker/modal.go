@@ -0,0 +1,171 @@
+package asker
+
+// Renders the configured dialog elements as a Block Kit modal, and turns the
+// view_submission back into the same answers a dialog submission had.
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+type viewSubmission struct {
+	View struct {
+		CallbackID string `json:"callback_id"`
+		State      struct {
+			Values map[string]map[string]viewStateValue `json:"values"`
+		} `json:"state"`
+	} `json:"view"`
+}
+
+type viewOption struct {
+	Value string `json:"value"`
+}
+
+type viewStateValue struct {
+	Type                 string       `json:"type"`
+	Value                string       `json:"value"`
+	SelectedOption       *viewOption  `json:"selected_option"`
+	SelectedOptions      []viewOption `json:"selected_options"`
+	SelectedUser         string       `json:"selected_user"`
+	SelectedConversation string       `json:"selected_conversation"`
+	SelectedDate         string       `json:"selected_date"`
+}
+
+func plainText(text string) slackBlock {
+	return slackBlock{"type": "plain_text", "text": text}
+}
+
+// modalView renders the dialog as a modal for views.open
+func modalView(dialog Dialog) slackBlock {
+	var blocks []slackBlock
+	for _, element := range dialog.Elements {
+		block := slackBlock{
+			"type":     "input",
+			"block_id": element.Name,
+			"label":    plainText(element.Label),
+			"optional": element.Optional,
+			"element":  modalElement(element),
+		}
+		if element.Hint != "" {
+			block["hint"] = plainText(element.Hint)
+		}
+		blocks = append(blocks, block)
+	}
+
+	return slackBlock{
+		"type":        "modal",
+		"callback_id": dialog.CallbackID,
+		"title":       plainText(dialog.Title),
+		"submit":      plainText(dialog.SubmitLabel),
+		"close":       plainText("Cancel"),
+		"blocks":      blocks,
+	}
+}
+
+func modalElement(element DialogElement) slackBlock {
+	input := slackBlock{"action_id": element.Name}
+	if element.Placeholder != "" && element.Type != "checkboxes" {
+		input["placeholder"] = plainText(element.Placeholder)
+	}
+
+	switch element.Type {
+	case "text", "textarea":
+		input["type"] = "plain_text_input"
+		input["multiline"] = element.Type == "textarea"
+		if element.Value != "" {
+			input["initial_value"] = element.Value
+		}
+		if element.MinLength > 0 {
+			input["min_length"] = element.MinLength
+		}
+		if element.MaxLength > 0 {
+			input["max_length"] = element.MaxLength
+		}
+	case "select":
+		input["type"] = "static_select"
+		input["options"] = modalOptions(element.Options)
+		for _, option := range modalOptions(element.Options) {
+			if option["value"] == element.Value {
+				input["initial_option"] = option
+			}
+		}
+	case "multi_select", "checkboxes":
+		input["type"] = "multi_static_select"
+		if element.Type == "checkboxes" {
+			input["type"] = "checkboxes"
+		}
+		input["options"] = modalOptions(element.Options)
+		var initial []slackBlock
+		for _, option := range modalOptions(element.Options) {
+			for _, value := range strings.Split(element.Value, ",") {
+				if option["value"] == value {
+					initial = append(initial, option)
+				}
+			}
+		}
+		if len(initial) > 0 {
+			input["initial_options"] = initial
+		}
+	case "external_select":
+		// Options come from OptionsHandler as people type
+		input["type"] = "external_select"
+		input["min_query_length"] = 0
+	case "user":
+		input["type"] = "users_select"
+	case "channel":
+		input["type"] = "conversations_select"
+		input["default_to_current_conversation"] = true
+	case "date":
+		input["type"] = "datepicker"
+		if element.Value != "" {
+			input["initial_date"] = element.Value
+		}
+	}
+	return input
+}
+
+func modalOptions(options []DialogOption) []slackBlock {
+	var rendered []slackBlock
+	for _, option := range options {
+		rendered = append(rendered, slackBlock{"text": plainText(option.Label), "value": option.Value})
+	}
+	return rendered
+}
+
+// parseViewSubmission flattens the modal's state into element answers. Multiple
+// choices are joined with commas, and people and channels are Slack mentions.
+func (a *Asker) parseViewSubmission(token string, payload []byte, request *InteractiveRequest) error {
+	submission := viewSubmission{}
+	if err := json.Unmarshal(payload, &submission); err != nil {
+		return err
+	}
+
+	request.CallbackID = submission.View.CallbackID
+	request.Submission = map[string]string{}
+	for blockID, actions := range submission.View.State.Values {
+		for _, state := range actions {
+			request.Submission[blockID] = a.viewStateAnswer(token, state)
+		}
+	}
+	return nil
+}
+
+func (a *Asker) viewStateAnswer(token string, state viewStateValue) string {
+	switch {
+	case state.SelectedOption != nil:
+		return state.SelectedOption.Value
+	case state.SelectedOptions != nil:
+		var values []string
+		for _, option := range state.SelectedOptions {
+			values = append(values, option.Value)
+		}
+		return strings.Join(values, ",")
+	case state.SelectedUser != "":
+		return a.labelMentions(token, "<@"+state.SelectedUser+">")
+	case state.SelectedConversation != "":
+		return a.labelMentions(token, "<#"+state.SelectedConversation+">")
+	case state.SelectedDate != "":
+		return state.SelectedDate
+	}
+	return state.Value
+}
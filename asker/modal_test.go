@@ -0,0 +1,25 @@
+package asker
+
+import "testing"
+
+func TestViewStateAnswer(t *testing.T) {
+	a := &Asker{}
+
+	tests := []struct {
+		name  string
+		state viewStateValue
+		want  string
+	}{
+		{"text", viewStateValue{Type: "plain_text_input", Value: "It's broken"}, "It's broken"},
+		{"empty text", viewStateValue{Type: "plain_text_input"}, ""},
+		{"select", viewStateValue{Type: "static_select", SelectedOption: &viewOption{Value: "yes"}}, "yes"},
+		{"multi select", viewStateValue{Type: "multi_static_select", SelectedOptions: []viewOption{{Value: "api"}, {Value: "ui"}}}, "api,ui"},
+		{"nothing checked", viewStateValue{Type: "checkboxes", SelectedOptions: []viewOption{}}, ""},
+		{"date", viewStateValue{Type: "datepicker", SelectedDate: "2020-01-31"}, "2020-01-31"},
+	}
+	for _, test := range tests {
+		if got := a.viewStateAnswer("", test.state); got != test.want {
+			t.Errorf("%s: viewStateAnswer = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
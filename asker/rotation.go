@@ -0,0 +1,244 @@
+package asker
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jshirley/slack-ask/storage"
+
+	"gopkg.in/mgo.v2"
+)
+
+// Slack sends mentions in slash commands as <@U123|name> (or just <@U123>)
+var mentionPattern = regexp.MustCompile(`^<@([A-Z0-9]+)(?:\|([^>]+))?>$`)
+
+func parseMember(mention string, name string) (storage.RotationMember, error) {
+	matches := mentionPattern.FindStringSubmatch(mention)
+	if matches == nil {
+		return storage.RotationMember{}, fmt.Errorf("`%s` is not a Slack user, mention them like @someone", mention)
+	}
+
+	member := storage.RotationMember{SlackID: matches[1], Name: name}
+	if member.Name == "" {
+		member.Name = matches[2]
+	}
+	return member, nil
+}
+
+func describeRotation(rotation *storage.Rotation) string {
+	if len(rotation.Members) == 0 {
+		return "Nobody is in the rotation yet. Use `/ask rotation add @someone [jira-username]` to add people."
+	}
+
+	var members []string
+	for _, member := range rotation.Members {
+		members = append(members, fmt.Sprintf("<@%s> (%s)", member.SlackID, member.Name))
+	}
+
+	schedule := "Asks are handed out round robin"
+	if !rotation.RoundRobin {
+		shift := time.Duration(rotation.ShiftLength) * time.Second
+		if shift <= 0 {
+			shift = storage.DEFAULT_SHIFT
+		}
+		schedule = fmt.Sprintf("Shifts are %s, handing off at %s UTC", shift, time.Unix(rotation.Handoff, 0).UTC().Format("15:04"))
+	}
+
+	text := fmt.Sprintf("Rotation: %s\n%s", strings.Join(members, ", "), schedule)
+	now := time.Now()
+	for _, override := range rotation.Overrides {
+		if override.End > now.Unix() {
+			text += fmt.Sprintf("\nOverride: <@%s> until %s", override.Member.SlackID, time.Unix(override.End, 0).UTC().Format(time.RFC1123))
+		}
+	}
+	if onCall := rotation.OnCall(now); onCall != nil {
+		text += fmt.Sprintf("\nUp next: <@%s>", onCall.SlackID)
+	}
+	return text
+}
+
+func (a *Asker) handleRotationCommand(db storage.DataLayer, command *storage.SlashCommand, w http.ResponseWriter, r *http.Request) {
+	commands := strings.Fields(command.Text)
+
+	rotation, err := db.GetRotation(command.ChannelID)
+	if err == mgo.ErrNotFound {
+		rotation = &storage.Rotation{ChannelID: command.ChannelID, Handoff: time.Now().Unix()}
+	} else if err != nil {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, fmt.Sprintf("Unable to load the rotation, the error from storage is: %+v", err))
+		return
+	}
+
+	if len(commands) == 1 || commands[1] == "list" {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, describeRotation(rotation))
+		return
+	}
+
+	var reply string
+	switch {
+	case commands[1] == "add" && (len(commands) == 3 || len(commands) == 4):
+		name := ""
+		if len(commands) == 4 {
+			name = commands[3]
+		}
+		member, parseErr := parseMember(commands[2], name)
+		if parseErr != nil {
+			err = parseErr
+			break
+		}
+		if member.Name == "" {
+			member.Name = a.slackUsername(a.teamToken(db, command.TeamID), member.SlackID)
+		}
+		if existing := findMember(rotation, member); existing != nil {
+			// Twice in the rotation would be twice the asks
+			reply = fmt.Sprintf("<@%s> is already in the rotation (as %s)!", existing.SlackID, existing.Name)
+			break
+		}
+		rotation.Members = append(rotation.Members, member)
+		reply = fmt.Sprintf("Got it, added <@%s> to the rotation!", member.SlackID)
+	case commands[1] == "remove" && len(commands) == 3:
+		member, parseErr := parseMember(commands[2], "")
+		if parseErr != nil {
+			err = parseErr
+			break
+		}
+		var members []storage.RotationMember
+		for _, existing := range rotation.Members {
+			if existing.SlackID != member.SlackID {
+				members = append(members, existing)
+			}
+		}
+		rotation.Members = members
+		reply = fmt.Sprintf("Got it, removed <@%s> from the rotation!", member.SlackID)
+	case commands[1] == "override" && len(commands) >= 4:
+		hours, parseErr := strconv.Atoi(commands[3])
+		if parseErr != nil || hours < 1 {
+			err = fmt.Errorf("Overrides are a number of hours, like `/ask rotation override @someone 8`")
+			break
+		}
+		name := ""
+		if len(commands) > 4 {
+			name = commands[4]
+		}
+		member, parseErr := parseMember(commands[2], name)
+		if parseErr != nil {
+			err = parseErr
+			break
+		}
+		for _, existing := range rotation.Members {
+			if existing.SlackID == member.SlackID && name == "" {
+				member.Name = existing.Name
+			}
+		}
+		if member.Name == "" {
+			member.Name = a.slackUsername(a.teamToken(db, command.TeamID), member.SlackID)
+		}
+		start := time.Now()
+		end := start.Add(time.Duration(hours) * time.Hour)
+		rotation.Overrides = append(activeOverrides(rotation, start), storage.RotationOverride{Member: member, Start: start.Unix(), End: end.Unix()})
+		reply = fmt.Sprintf("Got it, <@%s> is on call until %s!", member.SlackID, end.UTC().Format(time.RFC1123))
+	case commands[1] == "shift" && (len(commands) == 3 || len(commands) == 4):
+		hours, parseErr := strconv.Atoi(commands[2])
+		if parseErr != nil || hours < 1 {
+			err = fmt.Errorf("Shifts are a number of hours, like `/ask rotation shift 168 09:00`")
+			break
+		}
+		rotation.ShiftLength = int64(hours) * 3600
+		rotation.RoundRobin = false
+		if len(commands) == 4 {
+			handoff, parseErr := time.Parse("15:04", commands[3])
+			if parseErr != nil {
+				err = fmt.Errorf("Handoff times are in UTC like 09:00, not `%s`", commands[3])
+				break
+			}
+			now := time.Now().UTC()
+			rotation.Handoff = time.Date(now.Year(), now.Month(), now.Day(), handoff.Hour(), handoff.Minute(), 0, 0, time.UTC).Unix()
+		}
+		reply = fmt.Sprintf("Got it, shifts are now %d hours!", hours)
+	case commands[1] == "roundrobin":
+		rotation.RoundRobin = true
+		reply = "Got it, asks will be handed out round robin!"
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Invalid rotation command. Available options are `/ask rotation list`, `/ask rotation add @someone [jira-username]`, `/ask rotation remove @someone`, `/ask rotation override @someone <hours>`, `/ask rotation shift <hours> [HH:MM]` and `/ask rotation roundrobin`")
+		return
+	}
+
+	if err == nil {
+		err = db.SetRotation(rotation)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, fmt.Sprintf("Unable to update the rotation: %+v", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, reply)
+}
+
+// findMember is the member already in the rotation as the same Slack user, or
+// failing that the same backend user
+func findMember(rotation *storage.Rotation, member storage.RotationMember) *storage.RotationMember {
+	for i, existing := range rotation.Members {
+		if existing.SlackID == member.SlackID {
+			return &rotation.Members[i]
+		}
+	}
+	for i, existing := range rotation.Members {
+		if member.Name != "" && strings.ToLower(existing.Name) == strings.ToLower(member.Name) {
+			return &rotation.Members[i]
+		}
+	}
+	return nil
+}
+
+// slackUsername is who Slack says the user is, for members added without a
+// name since Slack stopped sending one with mentions
+func (a *Asker) slackUsername(token string, slackID string) string {
+	user, err := a.slackAPI(token).GetUserInfo(slackID)
+	if err != nil {
+		log.Printf("Unable to look up the name of %s: %v\n", slackID, err)
+		return slackID
+	}
+	return firstNonEmpty(user.Name, user.RealName, slackID)
+}
+
+// activeOverrides drops overrides that are over, so they don't pile up
+func activeOverrides(rotation *storage.Rotation, now time.Time) []storage.RotationOverride {
+	var overrides []storage.RotationOverride
+	for _, override := range rotation.Overrides {
+		if override.End > now.Unix() {
+			overrides = append(overrides, override)
+		}
+	}
+	return overrides
+}
+
+// rotationAssignee is whoever is on call in the channel's rotation, if it has one
+func (a *Asker) rotationAssignee(db storage.DataLayer, channelID string) (*Assignee, error) {
+	rotation, err := db.GetRotation(channelID)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if rotation.RoundRobin && len(rotation.Members) > 0 && len(activeOverrides(rotation, time.Now())) == 0 {
+		if rotation, err = db.AdvanceRotation(channelID); err != nil {
+			return nil, err
+		}
+	}
+
+	member := rotation.OnCall(time.Now())
+	if member == nil {
+		return nil, nil
+	}
+	return &Assignee{Name: member.Name, SlackID: member.SlackID}, nil
+}
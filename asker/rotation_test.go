@@ -0,0 +1,57 @@
+package asker
+
+import (
+	"testing"
+
+	"github.com/jshirley/slack-ask/storage"
+)
+
+func TestParseMember(t *testing.T) {
+	tests := []struct {
+		mention string
+		name    string
+		want    storage.RotationMember
+		err     bool
+	}{
+		{"<@U123|jane>", "", storage.RotationMember{SlackID: "U123", Name: "jane"}, false},
+		{"<@U123|jane>", "jdoe", storage.RotationMember{SlackID: "U123", Name: "jdoe"}, false},
+		{"<@U123>", "", storage.RotationMember{SlackID: "U123"}, false},
+		{"@jane", "", storage.RotationMember{}, true},
+		{"<#C123|general>", "", storage.RotationMember{}, true},
+	}
+	for _, test := range tests {
+		got, err := parseMember(test.mention, test.name)
+		if test.err {
+			if err == nil {
+				t.Errorf("parseMember(%q, %q) = %+v, want an error", test.mention, test.name, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("parseMember(%q, %q) = %+v, %v, want %+v", test.mention, test.name, got, err, test.want)
+		}
+	}
+}
+
+func TestFindMember(t *testing.T) {
+	rotation := &storage.Rotation{Members: []storage.RotationMember{
+		{SlackID: "U1", Name: "jane"},
+		{SlackID: "U2", Name: "sam"},
+	}}
+
+	tests := []struct {
+		member storage.RotationMember
+		want   string
+	}{
+		{storage.RotationMember{SlackID: "U2", Name: "samuel"}, "U2"},
+		{storage.RotationMember{SlackID: "U9", Name: "Jane"}, "U1"},
+		{storage.RotationMember{SlackID: "U9", Name: "alex"}, ""},
+		{storage.RotationMember{SlackID: "U9"}, ""},
+	}
+	for _, test := range tests {
+		got := findMember(rotation, test.member)
+		if (got == nil && test.want != "") || (got != nil && got.SlackID != test.want) {
+			t.Errorf("findMember(%+v) = %+v, want %q", test.member, got, test.want)
+		}
+	}
+}
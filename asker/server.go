@@ -2,6 +2,7 @@ package asker
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
@@ -15,20 +16,34 @@ import (
 )
 
 type Asker struct {
-	OAuth          string
-	Token          string
-	api            *slack.Client
-	storage        storage.Session
-	Jira           *JiraClient
-	dialogElements []DialogElement
+	OAuth             string
+	Token             string
+	SigningSecret     string
+	ClientID          string
+	ClientSecret      string
+	PublicURL         string
+	WebhookSecret     string
+	JiraWebhookSecret string
+	WebhookHosts      []string
+	api               *slack.Client
+	storage           storage.Session
+	backends          map[string]TicketBackend
+	dialogElements    []DialogElement
+	fieldMappings     []FieldMapping
+
+	// legacyWorkspace is the --oauth workspace data from before workspaces moved into
+	legacyWorkspace string
 }
 
 func NewAsker(oAuthToken string, token string, mongodb string) (*Asker, error) {
 	client := Asker{
-		OAuth:   oAuthToken,
-		Token:   token,
-		api:     slack.New(oAuthToken),
-		storage: storage.NewSession(mongodb),
+		OAuth:    oAuthToken,
+		Token:    token,
+		api:      slack.New(oAuthToken),
+		storage:  storage.NewSession(mongodb),
+		backends: map[string]TicketBackend{},
+
+		dialogElements: defaultElements(),
 	}
 
 	return &client, nil
@@ -39,9 +54,22 @@ func (a *Asker) Listen(addr string) {
 
 	r := mux.NewRouter()
 	r.HandleFunc("/", a.RootHandler)
-	r.HandleFunc("/events/ask", a.AskHandler)
-	r.HandleFunc("/events/request", a.DialogRequestHandler)
-	r.HandleFunc("/events/options", a.OptionsHandler)
+	r.Handle("/events/ask", a.SlackVerification(http.HandlerFunc(a.AskHandler)))
+	r.Handle("/events/request", a.SlackVerification(http.HandlerFunc(a.InteractionHandler)))
+	r.Handle("/events/options", a.SlackVerification(http.HandlerFunc(a.OptionsHandler)))
+	r.Handle("/events/slack", a.SlackVerification(http.HandlerFunc(a.SlackEventHandler)))
+	if a.JiraWebhookSecret != "" {
+		r.HandleFunc("/events/jira", a.JiraEventHandler)
+	} else {
+		log.Println("No JIRA webhook secret is set, JIRA updates will not be posted to threads")
+	}
+	r.HandleFunc("/install", a.InstallHandler)
+	r.HandleFunc("/oauth/redirect", a.OAuthRedirectHandler)
+	r.HandleFunc("/questions/{workspace}/{project}/{key}", a.QuestionHandler)
+	if a.legacyWorkspace != "" {
+		// Links to questions from before they were per workspace
+		r.HandleFunc("/questions/{project}/{key}", a.QuestionHandler)
+	}
 
 	//http.Handle("/", StorageMiddleware(r, a.storage))
 	http.ListenAndServe(addr, StorageMiddleware(r, a.storage))
@@ -86,14 +114,25 @@ func MgoDBFromRequest(r *http.Request) storage.DataLayer {
 }
 
 func (a *Asker) handleChannelLink(db storage.DataLayer, command *storage.SlashCommand) (string, error) {
-	s := strings.Split(command.Text, " ")
-	cmd, project := s[0], s[1]
-	if cmd != "link" {
-		return "", fmt.Errorf("Invalid command, use /%s link <jira project>", command.Command)
+	s := strings.Fields(command.Text)
+	if len(s) < 2 || s[0] != "link" {
+		return "", fmt.Errorf("Invalid command, use /%s link <jira project> [backend:project ...]", command.Command)
+	}
+
+	var targets []storage.LinkTarget
+	for _, arg := range s[1:] {
+		target, err := a.parseLinkTarget(arg)
+		if err != nil {
+			return "", err
+		}
+		if err := a.validateTarget(target); err != nil {
+			return "", err
+		}
+		targets = append(targets, target)
 	}
 
-	err := db.SetChannelProject(command.ChannelID, project)
-	return project, err
+	err := db.SetChannelProject(command.ChannelID, command.ChannelName, targets)
+	return describeTargets(targets), err
 }
 
 func (a *Asker) RootHandler(w http.ResponseWriter, r *http.Request) {
@@ -117,7 +156,11 @@ func (a *Asker) handleConfigCommand(db storage.DataLayer, command *storage.Slash
 		if components == "" {
 			components = "None! Use `/ask config components Component1 Component2` to set them"
 		}
-		fmt.Fprintf(w, fmt.Sprintf("This channel is set to JIRA project: %s\nDefault components: %s", config.Project, components))
+		issueType := config.IssueType
+		if issueType == "" {
+			issueType = "The project default. Use `/ask config type Task` to set it"
+		}
+		fmt.Fprintf(w, fmt.Sprintf("This channel is set to %s\nDefault components: %s\nIssue type: %s\nBlocking priorities: %s\nAssigned by: %s\nThread replies: %s", describeTargets(config.LinkTargets()), components, issueType, describePriorities(config), describeAssignment(config), describeMirroring(config)))
 	} else if commands[1] == "components" {
 		config.Components = commands[2:len(commands)]
 		err := db.SetChannelConfig(config)
@@ -128,9 +171,70 @@ func (a *Asker) handleConfigCommand(db storage.DataLayer, command *storage.Slash
 			w.WriteHeader(http.StatusOK)
 			fmt.Fprintf(w, fmt.Sprintf("Got it, default components for `%s` are now %v!", config.Project, config.Components))
 		}
+	} else if commands[1] == "priority" && len(commands) > 3 && strings.ContainsAny(commands[2], ".$") {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, fmt.Sprintf("Unable to map `%s`, answers can't have `.` or `$` in them", commands[2]))
+	} else if commands[1] == "priority" && len(commands) > 3 {
+		if config.Priorities == nil {
+			config.Priorities = map[string]storage.PriorityMapping{}
+		}
+		config.Priorities[commands[2]] = storage.PriorityMapping{Priority: commands[3], Labels: commands[4:]}
+		err := db.SetChannelConfig(config)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, fmt.Sprintf("Unable to store configuration: %+v", err))
+		} else {
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, fmt.Sprintf("Got it, blocking asks answered `%s` will now be %s priority with labels %v!", commands[2], commands[3], commands[4:]))
+		}
+	} else if commands[1] == "assign" && len(commands) == 3 {
+		endpoint := strings.TrimSuffix(strings.TrimPrefix(commands[2], "<"), ">")
+		if endpoint == "none" {
+			endpoint = ""
+		}
+		var err error
+		if endpoint != "" && a.WebhookSecret == "" {
+			err = fmt.Errorf("assignment requests are signed, start slack-ask with --webhooksecret first")
+		} else if endpoint != "" {
+			err = validateEndpoint(endpoint, a.WebhookHosts)
+		}
+		if err == nil {
+			config.AssignEndpoint = endpoint
+			err = db.SetChannelConfig(config)
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, fmt.Sprintf("Unable to set the assignment endpoint: %+v", err))
+		} else {
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, fmt.Sprintf("Got it, asks in this channel will be assigned by %s!", describeAssignment(config)))
+		}
+	} else if commands[1] == "mirror" && len(commands) == 3 && (commands[2] == "on" || commands[2] == "off") {
+		config.MirrorThreads = commands[2] == "on"
+		err := db.SetChannelConfig(config)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, fmt.Sprintf("Unable to store configuration: %+v", err))
+		} else {
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, fmt.Sprintf("Got it, replies in ask threads will %s", describeMirroring(config)))
+		}
+	} else if commands[1] == "type" && len(commands) > 2 {
+		issueType, err := a.findIssueType(config, strings.Join(commands[2:], " "))
+		if err == nil {
+			config.IssueType = issueType
+			err = db.SetChannelConfig(config)
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, fmt.Sprintf("Unable to set the issue type: %+v", err))
+		} else {
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, fmt.Sprintf("Got it, asks in this channel will now be a %s!", config.IssueType))
+		}
 	} else {
 		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(w, fmt.Sprintf("Invalid config command. Available options are `/ask config`, `/ask config components Comp1 Comp2`, and maybe more. Patches welcome!"))
+		fmt.Fprintf(w, fmt.Sprintf("Invalid config command. Available options are `/ask config`, `/ask config components Comp1 Comp2`, `/ask config type Task`, `/ask config priority 911 Highest urgent`, `/ask config assign https://...`, `/ask config mirror on`, and maybe more. Patches welcome!"))
 	}
 }
 
@@ -139,12 +243,16 @@ func (a *Asker) AskHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		fmt.Fprintf(w, "Bad request")
+		return
 	}
 
-	db := MgoDBFromRequest(r)
+	db := MgoDBFromRequest(r).ForWorkspace(command.TeamID, command.EnterpriseID)
 	if strings.HasPrefix(command.Text, "config") {
 		a.handleConfigCommand(db, command, w, r)
 		return
+	} else if strings.HasPrefix(command.Text, "rotation") {
+		a.handleRotationCommand(db, command, w, r)
+		return
 	} else if strings.HasPrefix(command.Text, "link ") {
 		project, err := a.handleChannelLink(db, command)
 		if err != nil {
@@ -154,7 +262,7 @@ func (a *Asker) AskHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, fmt.Sprintf("Got it, set %s JIRA project to %s", command.ChannelName, project))
+		fmt.Fprintf(w, fmt.Sprintf("Got it, set %s project to %s", command.ChannelName, project))
 		return
 	}
 
@@ -181,12 +289,35 @@ func (a *Asker) AskHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("Got incoming /ask request, deserialize request:\n%+v\n", command)
-	a.OpenDialog(callbackID, config, command.TriggerID)
+	if err := a.OpenDialog(a.teamToken(db, command.TeamID), callbackID, config, command.TriggerID, nil); err != nil {
+		log.Printf("Unable to open the ask form: %v\n", err)
+	}
 	w.WriteHeader(http.StatusOK)
 	// Send an empty response, because we'll use the responseURL later
 	fmt.Fprintf(w, "")
 }
 
+// InteractionHandler routes everything Slack sends to the interactivity URL
+func (a *Asker) InteractionHandler(w http.ResponseWriter, r *http.Request) {
+	interaction := struct {
+		Type string `json:"type"`
+	}{}
+	if err := r.ParseForm(); err == nil {
+		json.Unmarshal([]byte(r.FormValue("payload")), &interaction)
+	}
+
+	switch interaction.Type {
+	case "block_actions":
+		a.BlockActionHandler(w, r)
+	case "message_action":
+		a.MessageShortcutHandler(w, r)
+	case "shortcut":
+		a.GlobalShortcutHandler(w, r)
+	default:
+		a.DialogRequestHandler(w, r)
+	}
+}
+
 func (a *Asker) DialogRequestHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Handling incoming response for dialog, verifying authenticity\n")
 	request, err := a.parseInteractiveRequest(r)
@@ -194,35 +325,96 @@ func (a *Asker) DialogRequestHandler(w http.ResponseWriter, r *http.Request) {
 		log.Printf("Failed verifying interactive request: %+v\n", err)
 		w.WriteHeader(http.StatusBadRequest)
 		fmt.Fprintf(w, "Bad request")
+		return
 	}
 
-	db := MgoDBFromRequest(r)
-	config, err := db.GetChannelConfig(request.Channel.Id)
-	if err != nil || config == nil {
-		log.Printf("Unable to fetch channel configuration for %s: %+v\n", request.Channel.Id, err)
+	db := MgoDBFromRequest(r).ForWorkspace(request.Team.Id, request.Enterprise.Id)
+	originalAsk, err := db.GetCallback(request.CallbackID)
+	if err != nil {
+		log.Printf("This is strange! We have a dialog with request ID %s, but that is not in the storage queue\n", request.CallbackID)
 		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(w, "Bad request, unable to find configuration for the channel requested")
+		fmt.Fprintf(w, "I seem to have lost this request, which is unfortunate. I tried to store it in Mongo but now cannot find it.")
+		return
 	}
 
-	if originalAsk, err := db.GetCallback(request.CallbackID); err == nil {
-		originalAsk.Config = config
-		if err = a.PostAskResult(originalAsk, request); err != nil {
-			log.Printf("Unable to post response back: %v\n", err)
+	pickedChannel := originalAsk.ChannelID == ""
+	if pickedChannel {
+		// Asked from the global shortcut, to whichever channel they picked as
+		// long as they're in it
+		originalAsk.ChannelID = request.Submission[ASK_CHANNEL_ELEMENT]
+		channels, err := usersConversations(a.teamToken(db, originalAsk.TeamID), originalAsk.UserID)
+		if _, ok := channels[originalAsk.ChannelID]; err != nil || !ok {
+			log.Printf("%s picked %s, which they aren't in: %v\n", originalAsk.UserID, originalAsk.ChannelID, err)
+			formErrors(w, map[string]string{ASK_CHANNEL_ELEMENT: "You can only ask in channels you're in"})
+			return
+		}
+	}
+	// Modals don't say which channel they were opened from, but the ask does
+	config, err := db.GetChannelConfig(originalAsk.ChannelID)
+	if err != nil || config == nil {
+		log.Printf("Unable to fetch channel configuration for %s: %+v\n", originalAsk.ChannelID, err)
+		if request.Type == "view_submission" {
+			blockID := a.dialogElements[0].Name
+			if pickedChannel {
+				blockID = ASK_CHANNEL_ELEMENT
+			}
+			formErrors(w, map[string]string{blockID: "That channel isn't linked to a project anymore, pick another or `/ask link` it first"})
+			return
 		}
-		db.RemoveCallback(request.CallbackID)
-	} else {
-		log.Printf("This is strange! We have a dialog with request ID %s, but that is not in the storage queue\n", request.CallbackID)
 		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(w, "I seem to have lost this request, which is unfortunate. I tried to store it in Mongo but now cannot find it.")
+		fmt.Fprintf(w, "Bad request, unable to find configuration for the channel requested")
 		return
 	}
+	originalAsk.Config = config
+	if originalAsk.ChannelName == "" {
+		originalAsk.ChannelName = config.ChannelName
+	}
+	db.RemoveCallback(request.CallbackID)
 
+	// Slack only waits 3 seconds, creating the tickets takes longer
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprintf(w, "")
+
+	a.inBackground(request.Team.Id, request.Enterprise.Id, func(db storage.DataLayer) {
+		if err := a.PostAskResult(db, originalAsk, request); err != nil {
+			log.Printf("Unable to post response back: %v\n", err)
+		}
+	})
+}
+
+// formErrors keeps the modal open with errors shown under its inputs, by block ID
+func formErrors(w http.ResponseWriter, errors map[string]string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"response_action": "errors",
+		"errors":          errors,
+	})
+}
+
+// inBackground does work after the handler has answered Slack, with its own
+// storage session since the request's is closed once the handler returns
+func (a *Asker) inBackground(teamID string, enterpriseID string, work func(db storage.DataLayer)) {
+	go func() {
+		dbSession := a.storage.Copy()
+		defer dbSession.Close()
+		work(dbSession.DB("slack-ask").ForWorkspace(teamID, enterpriseID))
+	}()
 }
 
 func (a *Asker) OptionsHandler(w http.ResponseWriter, r *http.Request) {
 	log.Println("Got an options request")
+	suggestion := struct {
+		Type string `json:"type"`
+	}{}
+	if err := r.ParseForm(); err == nil {
+		json.Unmarshal([]byte(r.FormValue("payload")), &suggestion)
+	}
+	if suggestion.Type == "block_suggestion" {
+		a.ChannelOptionsHandler(w, r)
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprintf(w, "Ok")
 }
@@ -238,6 +430,36 @@ func (a *Asker) GetGroups() {
 	}
 }
 
+// PrepareStorage indexes storage, and moves anything stored before data was
+// kept per workspace into the --oauth workspace
+func (a *Asker) PrepareStorage() error {
+	dbSession := a.storage.Copy()
+	defer dbSession.Close()
+	db := dbSession.DB("slack-ask")
+
+	if err := db.EnsureIndexes(); err != nil {
+		return err
+	}
+	if a.OAuth == "" {
+		// Only the --oauth token says which workspace older data belongs to
+		count, err := db.CountUnscoped()
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			log.Printf("Found %d channel links, questions and other data from before data was kept per workspace, but without --oauth there is no workspace to move them into. No workspace will see them until slack-ask is started once with --oauth for the workspace they belong to.\n", count)
+		}
+		return nil
+	}
+
+	auth, err := authTest(a.OAuth)
+	if err != nil {
+		return fmt.Errorf("Unable to find the workspace to move existing data into: %v", err)
+	}
+	a.legacyWorkspace = storage.WorkspaceKey(auth.TeamID, auth.EnterpriseID)
+	return db.MigrateWorkspace(auth.TeamID, auth.EnterpriseID)
+}
+
 const TIMEOUT = 5 * time.Minute
 
 func (a *Asker) CleanQueue() {
@@ -0,0 +1,343 @@
+package asker
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jshirley/slack-ask/storage"
+)
+
+// ASK_MESSAGE_SHORTCUT is the callback ID of the "Ask about this message" shortcut
+const ASK_MESSAGE_SHORTCUT = "ask_about_message"
+
+// ASK_GLOBAL_SHORTCUT is the callback ID of the shortcut to ask from anywhere,
+// whose form starts with ASK_CHANNEL_ELEMENT to pick a linked channel
+const (
+	ASK_GLOBAL_SHORTCUT = "ask_anywhere"
+	ASK_CHANNEL_ELEMENT = "ask_channel"
+)
+
+// MAX_SELECT_OPTIONS is the most options a select in a modal can have, the
+// rest are found by typing
+const MAX_SELECT_OPTIONS = 100
+
+// MAX_PREFILL_LENGTH is the most a modal's text input accepts
+const MAX_PREFILL_LENGTH = 3000
+
+type MessageShortcutRequest struct {
+	Type        string     `json:"type"`
+	Token       string     `json:"token"`
+	CallbackID  string     `json:"callback_id"`
+	TriggerID   string     `json:"trigger_id"`
+	ResponseURL string     `json:"response_url"`
+	MessageTS   string     `json:"message_ts"`
+	Team        SlackTeam  `json:"team"`
+	Enterprise  SlackTuple `json:"enterprise"`
+	User        SlackTuple `json:"user"`
+	Channel     SlackTuple `json:"channel"`
+	Message     struct {
+		Text string `json:"text"`
+		User string `json:"user"`
+	} `json:"message"`
+}
+
+// MessageShortcutHandler opens the ask form from a message, with the message
+// quoted in the description and linked to
+func (a *Asker) MessageShortcutHandler(w http.ResponseWriter, r *http.Request) {
+	request := MessageShortcutRequest{}
+	if err := json.Unmarshal([]byte(r.FormValue("payload")), &request); err != nil {
+		log.Printf("Unable to decode message shortcut: %+v\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Bad request")
+		return
+	}
+
+	if !a.verifyToken(r, request.Token) {
+		log.Printf("Invalid token on message shortcut, check configuration or ensure someone isn't sending you bogus data\n")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Bad request")
+		return
+	}
+
+	if request.CallbackID != ASK_MESSAGE_SHORTCUT {
+		log.Printf("Got an unknown message shortcut `%s`\n", request.CallbackID)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	db := MgoDBFromRequest(r).ForWorkspace(request.Team.Id, request.Enterprise.Id)
+	config, err := db.GetChannelConfig(request.Channel.Id)
+	if err != nil {
+		respond(request.ResponseURL, SlackResponseResult{
+			ResponseType: "ephemeral",
+			Text:         "There is no /ask project configured for this channel. Use /ask link <PROJECT KEY> to link this channel to a JIRA project.",
+		})
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	command := &storage.SlashCommand{
+		TeamID:       request.Team.Id,
+		TeamDomain:   request.Team.Domain,
+		EnterpriseID: request.Enterprise.Id,
+		ChannelID:    request.Channel.Id,
+		ChannelName:  request.Channel.Name,
+		UserID:       request.User.Id,
+		UserName:     request.User.Name,
+		Command:      "/ask",
+		ResponseURL:  request.ResponseURL,
+		TriggerID:    request.TriggerID,
+		Timestamp:    time.Now().Unix(),
+		Config:       config,
+	}
+
+	var callbackID = fmt.Sprintf("ask-%s-%d", command.ChannelID, time.Now().UnixNano())
+	if err := db.StoreCallback(callbackID, command); err != nil {
+		log.Printf("Unable to store %s: %+v\n", callbackID, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "Internal Error storing callback: %+v", err)
+		return
+	}
+
+	token := a.teamToken(db, command.TeamID)
+	permalink, err := chatGetPermalink(token, request.Channel.Id, request.MessageTS)
+	if err != nil {
+		log.Printf("Unable to link to the message the ask is about: %v\n", err)
+	}
+	description := quoteMessage(request.Message.Text, permalink, a.prefillLength("description"))
+
+	if err := a.OpenDialog(token, callbackID, config, command.TriggerID, map[string]string{"description": description}); err != nil {
+		log.Printf("Unable to open the ask form: %v\n", err)
+	}
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "")
+}
+
+// quoteMessage quotes each line of the message and links to it, shortened so
+// the whole thing fits in maxLength
+func quoteMessage(text string, permalink string, maxLength int) string {
+	suffix := ""
+	if permalink != "" {
+		suffix = "\n\n" + permalink
+	}
+
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for i, line := range lines {
+		lines[i] = "> " + line
+	}
+	quoted := []rune(strings.Join(lines, "\n"))
+
+	room := maxLength - len([]rune(suffix))
+	if room < 2 {
+		// No room to quote anything, the link will have to do
+		if len([]rune(permalink)) > maxLength {
+			return ""
+		}
+		return permalink
+	}
+	if len(quoted) > room {
+		quoted = append(quoted[:room-1], '…')
+	}
+	return string(quoted) + suffix
+}
+
+// prefillLength is how much can be prefilled into the named element
+func (a *Asker) prefillLength(name string) int {
+	for _, element := range a.dialogElements {
+		if element.Name == name && element.MaxLength > 0 && element.MaxLength < MAX_PREFILL_LENGTH {
+			return element.MaxLength
+		}
+	}
+	return MAX_PREFILL_LENGTH
+}
+
+type GlobalShortcutRequest struct {
+	Type       string     `json:"type"`
+	Token      string     `json:"token"`
+	CallbackID string     `json:"callback_id"`
+	TriggerID  string     `json:"trigger_id"`
+	Team       SlackTeam  `json:"team"`
+	Enterprise SlackTuple `json:"enterprise"`
+	User       SlackTuple `json:"user"`
+}
+
+// GlobalShortcutHandler opens the ask form from anywhere, like DMs or channels
+// that aren't linked, with a picker of the linked channels to ask in
+func (a *Asker) GlobalShortcutHandler(w http.ResponseWriter, r *http.Request) {
+	request := GlobalShortcutRequest{}
+	if err := json.Unmarshal([]byte(r.FormValue("payload")), &request); err != nil {
+		log.Printf("Unable to decode global shortcut: %+v\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Bad request")
+		return
+	}
+
+	if !a.verifyToken(r, request.Token) {
+		log.Printf("Invalid token on global shortcut, check configuration or ensure someone isn't sending you bogus data\n")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Bad request")
+		return
+	}
+
+	if request.CallbackID != ASK_GLOBAL_SHORTCUT {
+		log.Printf("Got an unknown global shortcut `%s`\n", request.CallbackID)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	db := MgoDBFromRequest(r).ForWorkspace(request.Team.Id, request.Enterprise.Id)
+	token := a.teamToken(db, request.Team.Id)
+
+	configs, err := db.GetChannelConfigs()
+	if err != nil {
+		log.Printf("Unable to list the linked channels: %v\n", err)
+		a.openView(token, request.TriggerID, noticeView("Sorry! I couldn't find the linked channels, please try again."))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if len(configs) == 0 {
+		a.openView(token, request.TriggerID, noticeView("No channels are linked to a project yet. Use `/ask link <PROJECT KEY>` in a channel first."))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// The channel is filled in from the picker when the form is submitted
+	command := &storage.SlashCommand{
+		TeamID:       request.Team.Id,
+		TeamDomain:   request.Team.Domain,
+		EnterpriseID: request.Enterprise.Id,
+		UserID:       request.User.Id,
+		UserName:     request.User.Name,
+		Command:      "/ask",
+		TriggerID:    request.TriggerID,
+		Timestamp:    time.Now().Unix(),
+	}
+
+	var callbackID = fmt.Sprintf("ask-%s-%d", command.UserID, time.Now().UnixNano())
+	if err := db.StoreCallback(callbackID, command); err != nil {
+		log.Printf("Unable to store %s: %+v\n", callbackID, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "Internal Error storing callback: %+v", err)
+		return
+	}
+
+	dialog := a.GetDialog(callbackID)
+	dialog.Elements = append([]DialogElement{channelPicker()}, dialog.Elements...)
+	if err := a.openView(token, request.TriggerID, modalView(dialog)); err != nil {
+		log.Printf("Unable to open the ask form: %v\n", err)
+	}
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "")
+}
+
+// channelPicker picks one of the linked channels, loaded by ChannelOptionsHandler
+func channelPicker() DialogElement {
+	return DialogElement{
+		Type:        "external_select",
+		Name:        ASK_CHANNEL_ELEMENT,
+		Label:       "Who should answer?",
+		Placeholder: "Pick a channel",
+	}
+}
+
+type BlockSuggestionRequest struct {
+	Type       string     `json:"type"`
+	Token      string     `json:"token"`
+	ActionID   string     `json:"action_id"`
+	Value      string     `json:"value"`
+	Team       SlackTeam  `json:"team"`
+	Enterprise SlackTuple `json:"enterprise"`
+	User       SlackTuple `json:"user"`
+}
+
+// ChannelOptionsHandler fills the channel picker with the linked channels the
+// person asking is in, matching what they've typed
+func (a *Asker) ChannelOptionsHandler(w http.ResponseWriter, r *http.Request) {
+	request := BlockSuggestionRequest{}
+	if err := json.Unmarshal([]byte(r.FormValue("payload")), &request); err != nil {
+		log.Printf("Unable to decode options request: %+v\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Bad request")
+		return
+	}
+
+	if !a.verifyToken(r, request.Token) {
+		log.Printf("Invalid token on options request, check configuration or ensure someone isn't sending you bogus data\n")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Bad request")
+		return
+	}
+
+	var options []DialogOption
+	if request.ActionID == ASK_CHANNEL_ELEMENT {
+		db := MgoDBFromRequest(r).ForWorkspace(request.Team.Id, request.Enterprise.Id)
+		var err error
+		options, err = linkedChannelOptions(db, a.teamToken(db, request.Team.Id), request.User.Id, request.Value)
+		if err != nil {
+			log.Printf("Unable to list the linked channels for %s: %v\n", request.User.Id, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"options": modalOptions(options)})
+}
+
+// linkedChannelOptions is the linked channels the user is in, and their
+// projects, so nobody can ask in or learn about channels they can't see
+func linkedChannelOptions(db storage.DataLayer, token string, userID string, query string) ([]DialogOption, error) {
+	configs, err := db.GetChannelConfigs()
+	if err != nil {
+		return nil, err
+	}
+	channels, err := usersConversations(token, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	query = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(query), "#"))
+	var options []DialogOption
+	for _, config := range configs {
+		name, ok := channels[config.ChannelID]
+		if !ok {
+			continue
+		}
+
+		var projects []string
+		for _, target := range config.LinkTargets() {
+			projects = append(projects, target.Project)
+		}
+		if !strings.Contains(strings.ToLower(name+" "+strings.Join(projects, " ")), query) {
+			continue
+		}
+
+		label := []rune(fmt.Sprintf("#%s (%s)", name, strings.Join(projects, ", ")))
+		if len(label) > MAX_OPTION_LENGTH {
+			label = append(label[:MAX_OPTION_LENGTH-1], '…')
+		}
+		options = append(options, DialogOption{Label: string(label), Value: config.ChannelID})
+	}
+
+	sort.Slice(options, func(i, j int) bool { return options[i].Label < options[j].Label })
+	if len(options) > MAX_SELECT_OPTIONS {
+		options = options[:MAX_SELECT_OPTIONS]
+	}
+	return options, nil
+}
+
+// noticeView is a modal that just says something
+func noticeView(text string) slackBlock {
+	return slackBlock{
+		"type":  "modal",
+		"title": plainText("Ask a Question"),
+		"close": plainText("Close"),
+		"blocks": []slackBlock{
+			slackBlock{"type": "section", "text": slackBlock{"type": "mrkdwn", "text": text}},
+		},
+	}
+}
@@ -0,0 +1,47 @@
+package asker
+
+import "testing"
+
+func TestQuoteMessage(t *testing.T) {
+	tests := []struct {
+		text      string
+		permalink string
+		maxLength int
+		want      string
+	}{
+		{"hello\nworld", "https://x.slack.com/p1", 3000, "> hello\n> world\n\nhttps://x.slack.com/p1"},
+		{"  hello  \n", "", 3000, "> hello"},
+		{"abcdefghij", "", 6, "> abc…"},
+		{"ünïcödé", "", 6, "> ünï…"},
+		{"abcdefghij", "https://x", 17, "> abc…\n\nhttps://x"},
+		{"hello", "https://x", 10, "https://x"},
+		{"hello", "https://x", 5, ""},
+	}
+	for _, test := range tests {
+		got := quoteMessage(test.text, test.permalink, test.maxLength)
+		if got != test.want {
+			t.Errorf("quoteMessage(%q, %q, %d) = %q, want %q", test.text, test.permalink, test.maxLength, got, test.want)
+		}
+		if len([]rune(got)) > test.maxLength {
+			t.Errorf("quoteMessage(%q, %q, %d) is %d characters long", test.text, test.permalink, test.maxLength, len([]rune(got)))
+		}
+	}
+}
+
+func TestPrefillLength(t *testing.T) {
+	a := &Asker{dialogElements: []DialogElement{
+		DialogElement{Type: "textarea", Name: "description", MaxLength: 500},
+		DialogElement{Type: "textarea", Name: "steps", MaxLength: 5000},
+	}}
+
+	tests := map[string]int{
+		"description": 500,
+		"steps":       MAX_PREFILL_LENGTH,
+		"missing":     MAX_PREFILL_LENGTH,
+	}
+	for name, want := range tests {
+		if got := a.prefillLength(name); got != want {
+			t.Errorf("prefillLength(%q) = %d, want %d", name, got, want)
+		}
+	}
+}
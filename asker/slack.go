@@ -3,6 +3,7 @@ package asker
 // Patching nlopes/slack to add dialog support mostly, because I was too lazy to fork it right now
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -13,6 +14,9 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
 )
 
 var SLACK_API string = "https://slack.com/api/"
@@ -31,7 +35,10 @@ func getHTTPClient() HTTPRequester {
 	return HTTPClient
 }
 
-var HTTPClient = &http.Client{}
+// HTTP_TIMEOUT keeps a slow backend or webhook from holding up an ask forever
+const HTTP_TIMEOUT = 15 * time.Second
+
+var HTTPClient = &http.Client{Timeout: HTTP_TIMEOUT}
 
 func parseResponseBody(body io.ReadCloser, intf *interface{}, debug bool) error {
 	response, err := ioutil.ReadAll(body)
@@ -92,3 +99,199 @@ func logResponse(resp *http.Response, debug bool) error {
 
 	return nil
 }
+
+type SlackMessage struct {
+	Channel   string `json:"channel"`
+	Timestamp string `json:"ts"`
+}
+
+type chatPostMessageResponse struct {
+	slack.SlackResponse
+	SlackMessage
+}
+
+// chatPostMessage posts the message as the bot, so unlike the response_url we
+// know where the message ended up. Pass threadTimestamp to reply in a thread.
+func chatPostMessage(token string, channel string, threadTimestamp string, message SlackResponseResult) (*SlackMessage, error) {
+	values, err := messageValues(token, channel, message)
+	if err != nil {
+		return nil, err
+	}
+	if threadTimestamp != "" {
+		values.Set("thread_ts", threadTimestamp)
+	}
+
+	response := chatPostMessageResponse{}
+	if err := post(context.Background(), "chat.postMessage", values, &response, false); err != nil {
+		return nil, err
+	}
+	if !response.Ok {
+		return nil, fmt.Errorf("Slack refused the message: %s", response.Error)
+	}
+	return &response.SlackMessage, nil
+}
+
+// chatUpdate replaces a message the bot posted
+func chatUpdate(token string, channel string, timestamp string, message SlackResponseResult) error {
+	values, err := messageValues(token, channel, message)
+	if err != nil {
+		return err
+	}
+	values.Set("ts", timestamp)
+
+	response := slack.SlackResponse{}
+	if err := post(context.Background(), "chat.update", values, &response, false); err != nil {
+		return err
+	}
+	if !response.Ok {
+		return fmt.Errorf("Slack refused the update: %s", response.Error)
+	}
+	return nil
+}
+
+func messageValues(token string, channel string, message SlackResponseResult) (url.Values, error) {
+	values := url.Values{
+		"token":   {token},
+		"channel": {channel},
+		"text":    {message.Text},
+	}
+	if len(message.Attachments) > 0 {
+		attachments, err := json.Marshal(message.Attachments)
+		if err != nil {
+			return nil, err
+		}
+		values.Set("attachments", string(attachments))
+	}
+	if len(message.Blocks) > 0 {
+		blocks, err := json.Marshal(message.Blocks)
+		if err != nil {
+			return nil, err
+		}
+		values.Set("blocks", string(blocks))
+	}
+	return values, nil
+}
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// escapeSlack escapes text from outside Slack, so it can't mention people or
+// channels and shows up as it was written
+func escapeSlack(text string) string {
+	return slackEscaper.Replace(text)
+}
+
+type chatGetPermalinkResponse struct {
+	slack.SlackResponse
+	Permalink string `json:"permalink"`
+}
+
+func chatGetPermalink(token string, channel string, timestamp string) (string, error) {
+	values := url.Values{
+		"token":      {token},
+		"channel":    {channel},
+		"message_ts": {timestamp},
+	}
+
+	response := chatGetPermalinkResponse{}
+	if err := post(context.Background(), "chat.getPermalink", values, &response, false); err != nil {
+		return "", err
+	}
+	if !response.Ok {
+		return "", fmt.Errorf("Unable to get the message permalink: %s", response.Error)
+	}
+	return response.Permalink, nil
+}
+
+// respond posts back to a slash command or interaction's response_url
+func respond(responseURL string, response SlackResponseResult) error {
+	responseJson, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("Error encoding JSON: %+v\n", err)
+		return err
+	}
+
+	req, err := http.NewRequest("POST", responseURL, bytes.NewBuffer(responseJson))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	req = req.WithContext(context.Background())
+	resp, err := getHTTPClient().Do(req)
+	if err != nil {
+		log.Printf("Error posting response back to Slack: %s\n", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		logResponse(resp, true)
+		return fmt.Errorf("Slack server error: %s.", resp.Status)
+	}
+
+	return nil
+}
+
+type usersConversationsResponse struct {
+	slack.SlackResponse
+	Channels []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"channels"`
+	ResponseMetadata struct {
+		NextCursor string `json:"next_cursor"`
+	} `json:"response_metadata"`
+}
+
+// usersConversations is the channels the user is in that the bot can see, by ID
+func usersConversations(token string, userID string) (map[string]string, error) {
+	channels := map[string]string{}
+	cursor := ""
+	for {
+		values := url.Values{
+			"token":            {token},
+			"user":             {userID},
+			"types":            {"public_channel,private_channel"},
+			"exclude_archived": {"true"},
+			"limit":            {"1000"},
+		}
+		if cursor != "" {
+			values.Set("cursor", cursor)
+		}
+
+		response := usersConversationsResponse{}
+		if err := post(context.Background(), "users.conversations", values, &response, false); err != nil {
+			return nil, err
+		}
+		if !response.Ok {
+			return nil, fmt.Errorf("Unable to list the user's channels: %s", response.Error)
+		}
+		for _, channel := range response.Channels {
+			channels[channel.ID] = channel.Name
+		}
+
+		cursor = response.ResponseMetadata.NextCursor
+		if cursor == "" {
+			return channels, nil
+		}
+	}
+}
+
+type authTestResponse struct {
+	slack.SlackResponse
+	TeamID       string `json:"team_id"`
+	EnterpriseID string `json:"enterprise_id"`
+	UserID       string `json:"user_id"`
+}
+
+// authTest is which workspace the token is for
+func authTest(token string) (*authTestResponse, error) {
+	response := authTestResponse{}
+	if err := post(context.Background(), "auth.test", url.Values{"token": {token}}, &response, false); err != nil {
+		return nil, err
+	}
+	if !response.Ok {
+		return nil, fmt.Errorf("Slack refused the token: %s", response.Error)
+	}
+	return &response, nil
+}
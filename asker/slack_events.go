@@ -0,0 +1,125 @@
+package asker
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/jshirley/slack-ask/storage"
+)
+
+// SLACK_COMMENT_PREFIX starts every comment mirrored from Slack, so the JIRA
+// webhook knows not to post it back into the thread
+const SLACK_COMMENT_PREFIX = "Replied in Slack by "
+
+type SlackEvent struct {
+	Type    string `json:"type"`
+	Channel string `json:"channel"`
+	User    string `json:"user"`
+	Text    string `json:"text"`
+	Subtype string `json:"subtype"`
+	BotID   string `json:"bot_id"`
+	TS      string `json:"ts"`
+	Thread  string `json:"thread_ts"`
+}
+
+type SlackEventCallback struct {
+	Token        string     `json:"token"`
+	Type         string     `json:"type"`
+	Challenge    string     `json:"challenge"`
+	TeamID       string     `json:"team_id"`
+	EnterpriseID string     `json:"enterprise_id"`
+	EventID      string     `json:"event_id"`
+	Event        SlackEvent `json:"event"`
+}
+
+// SlackEventHandler receives the Events API, mirroring replies in ask threads
+// onto their tickets for channels that opted in with `/ask config mirror on`
+func (a *Asker) SlackEventHandler(w http.ResponseWriter, r *http.Request) {
+	callback := SlackEventCallback{}
+	if err := json.NewDecoder(r.Body).Decode(&callback); err != nil {
+		log.Printf("Unable to decode Slack event: %+v\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Bad request")
+		return
+	}
+
+	if !a.verifyToken(r, callback.Token) {
+		log.Printf("Invalid token on Slack event, check configuration or ensure someone isn't sending you bogus data\n")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Bad request")
+		return
+	}
+
+	if callback.Type == "url_verification" {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, callback.Challenge)
+		return
+	}
+
+	// Slack retries when we're slow or fail, and only the first delivery should be acted on
+	db := MgoDBFromRequest(r)
+	if callback.EventID != "" {
+		if first, err := db.MarkEventHandled(callback.EventID); err != nil {
+			log.Printf("Unable to check whether event %s was already handled: %v\n", callback.EventID, err)
+		} else if !first {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	}
+
+	event := callback.Event
+	if callback.Type == "event_callback" && event.Type == "message" && event.Thread != "" && event.Thread != event.TS && event.Subtype == "" && event.BotID == "" {
+		// Slack only waits 3 seconds, and adding the comments can take longer
+		a.inBackground(callback.TeamID, callback.EnterpriseID, func(db storage.DataLayer) {
+			a.mirrorThreadReply(db, a.teamToken(db, callback.TeamID), &event)
+		})
+	} else if callback.Type == "event_callback" && event.Type == "app_uninstalled" {
+		if err := db.RemoveTeam(callback.TeamID); err != nil {
+			log.Printf("Unable to remove the token for uninstalled team %s: %v\n", callback.TeamID, err)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (a *Asker) mirrorThreadReply(db storage.DataLayer, token string, event *SlackEvent) {
+	config, err := db.GetChannelConfig(event.Channel)
+	if err != nil || !config.MirrorThreads {
+		return
+	}
+
+	messages, err := db.GetThreadAskMessages(event.Channel, event.Thread)
+	if err != nil || len(messages) == 0 {
+		return
+	}
+
+	name := event.User
+	if user, err := a.slackAPI(token).GetUserInfo(event.User); err == nil {
+		name = firstNonEmpty(user.RealName, user.Name)
+	}
+	body := fmt.Sprintf("%s%s:\n%s", SLACK_COMMENT_PREFIX, name, a.labelMentions(token, event.Text))
+
+	for _, message := range messages {
+		backend, err := a.GetBackend(message.Backend)
+		if err != nil {
+			continue
+		}
+		commenter, ok := backend.(Commenter)
+		if !ok {
+			continue
+		}
+		if err := commenter.AddComment(message.Project, message.Key, body); err != nil {
+			log.Printf("Unable to mirror reply onto %s: %v\n", message.Key, err)
+		}
+	}
+}
+
+func describeMirroring(config *storage.ChannelConfig) string {
+	if config.MirrorThreads {
+		return "be added as comments on the ticket"
+	}
+	return "stay in Slack (use `/ask config mirror on` to add them as comments)"
+}
@@ -0,0 +1,93 @@
+package asker
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/jshirley/slack-ask/storage"
+)
+
+// BackendUser is a user in a ticket backend. JIRA Cloud only knows users by
+// AccountID, JIRA Server by Name.
+type BackendUser struct {
+	Name      string
+	AccountID string
+}
+
+// UserResolver is implemented by backends that can find their own user from
+// a Slack user's email address
+type UserResolver interface {
+	FindUserByEmail(email string) (*BackendUser, error)
+}
+
+// resolveUser maps the Slack user to a backend user by email, caching the
+// result in storage. A nil user means there is no match.
+func (a *Asker) resolveUser(db storage.DataLayer, token string, backendName string, resolver UserResolver, slackID string) (*BackendUser, error) {
+	mapping, err := db.GetUserMapping(backendName, slackID)
+	if err == nil && time.Since(time.Unix(mapping.Updated, 0)) < storage.USER_MAPPING_TTL {
+		return backendUserFromMapping(mapping), nil
+	}
+
+	slackUser, err := a.slackAPI(token).GetUserInfo(slackID)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to look up Slack user %s: %v", slackID, err)
+	}
+	if slackUser.Profile.Email == "" {
+		return nil, fmt.Errorf("Slack user %s has no email, is the users:read.email scope missing?", slackID)
+	}
+
+	user, err := resolver.FindUserByEmail(slackUser.Profile.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	mapping = &storage.UserMapping{SlackID: slackID, Backend: backendName, Email: slackUser.Profile.Email}
+	if user != nil {
+		mapping.Name, mapping.AccountID = user.Name, user.AccountID
+	}
+	if err := db.SetUserMapping(mapping); err != nil {
+		log.Printf("Unable to cache the user mapping for %s: %v\n", slackID, err)
+	}
+
+	return user, nil
+}
+
+func backendUserFromMapping(mapping *storage.UserMapping) *BackendUser {
+	if mapping.Name == "" && mapping.AccountID == "" {
+		return nil
+	}
+	return &BackendUser{Name: mapping.Name, AccountID: mapping.AccountID}
+}
+
+var bareMentionPattern = regexp.MustCompile(`<([@#])([UWC][A-Z0-9]+)>`)
+
+// labelMentions turns <@U123> and <#C123> into <@U123|Jane Doe> and
+// <#C123|general>, so the text still reads well once it leaves Slack
+func (a *Asker) labelMentions(token string, text string) string {
+	api := a.slackAPI(token)
+	names := map[string]string{}
+
+	return bareMentionPattern.ReplaceAllStringFunc(text, func(mention string) string {
+		parts := bareMentionPattern.FindStringSubmatch(mention)
+		kind, id := parts[1], parts[2]
+
+		name, ok := names[id]
+		if !ok {
+			if kind == "@" {
+				if user, err := api.GetUserInfo(id); err == nil {
+					name = firstNonEmpty(user.RealName, user.Name)
+				}
+			} else if channel, err := api.GetChannelInfo(id); err == nil {
+				name = channel.Name
+			}
+			names[id] = name
+		}
+
+		if name == "" {
+			return mention
+		}
+		return fmt.Sprintf("<%s%s|%s>", kind, id, name)
+	})
+}
@@ -0,0 +1,87 @@
+package asker
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// REQUEST_MAX_AGE is how old a signed request from Slack can be before it is
+// treated as a replay
+const REQUEST_MAX_AGE = 5 * time.Minute
+
+// slackVerifiedKey marks requests whose signature SlackVerification checked
+type slackVerifiedKey struct{}
+
+// SlackVerification checks the X-Slack-Signature of requests from Slack. When
+// a request isn't signed and there is a legacy verification token, the
+// handlers check the token instead, so apps can migrate to signing secrets.
+func (a *Asker) SlackVerification(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature := r.Header.Get("X-Slack-Signature")
+		if a.SigningSecret == "" || signature == "" {
+			if a.Token == "" {
+				log.Printf("Rejecting unsigned request to %s, there is no verification token to fall back to\n", r.URL.Path)
+				w.WriteHeader(http.StatusUnauthorized)
+				fmt.Fprintf(w, "Bad request")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "Bad request")
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		if err := verifySignature(a.SigningSecret, r.Header.Get("X-Slack-Request-Timestamp"), body, signature, time.Now()); err != nil {
+			log.Printf("Rejecting request to %s: %v\n", r.URL.Path, err)
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, "Bad request")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), slackVerifiedKey{}, true)))
+	})
+}
+
+func verifySignature(secret string, timestamp string, body []byte, signature string, now time.Time) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid request timestamp `%s`", timestamp)
+	}
+	age := now.Sub(time.Unix(ts, 0))
+	if age > REQUEST_MAX_AGE || age < -REQUEST_MAX_AGE {
+		return fmt.Errorf("Request timestamp is %v off, it may be a replay", age)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return fmt.Errorf("Invalid signature, check the signing secret")
+	}
+	return nil
+}
+
+// verifyToken is whether the request was signed, or failing that carries the
+// legacy verification token
+func (a *Asker) verifyToken(r *http.Request, token string) bool {
+	if verified, _ := r.Context().Value(slackVerifiedKey{}).(bool); verified {
+		return true
+	}
+	return a.Token != "" && hmac.Equal([]byte(token), []byte(a.Token))
+}
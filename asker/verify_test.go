@@ -0,0 +1,46 @@
+package asker
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func slackSignature(secret string, timestamp string, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":" + body))
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySignature(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	body := "token=abc&text=PROJ"
+
+	tests := []struct {
+		name      string
+		timestamp string
+		signature string
+		now       time.Time
+		valid     bool
+	}{
+		{"signed", timestamp, slackSignature("secret", timestamp, body), now, true},
+		{"a little late", timestamp, slackSignature("secret", timestamp, body), now.Add(4 * time.Minute), true},
+		{"wrong secret", timestamp, slackSignature("other", timestamp, body), now, false},
+		{"unsigned", timestamp, "", now, false},
+		{"replayed", timestamp, slackSignature("secret", timestamp, body), now.Add(6 * time.Minute), false},
+		{"from the future", timestamp, slackSignature("secret", timestamp, body), now.Add(-6 * time.Minute), false},
+		{"no timestamp", "", slackSignature("secret", "", body), now, false},
+	}
+	for _, test := range tests {
+		err := verifySignature("secret", test.timestamp, []byte(body), test.signature, test.now)
+		if test.valid && err != nil {
+			t.Errorf("%s: got %v, want it to verify", test.name, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("%s: verified, want an error", test.name)
+		}
+	}
+}
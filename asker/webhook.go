@@ -0,0 +1,193 @@
+package asker
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jshirley/slack-ask/storage"
+)
+
+// WebhookClient posts asks to whatever endpoint the channel is linked to, so
+// internal tools can receive them without a dedicated backend.
+type WebhookClient struct {
+	secret string
+	hosts  []string
+}
+
+type webhookPayload struct {
+	Ticket     *TicketRequest        `json:"ticket"`
+	Command    *storage.SlashCommand `json:"command"`
+	Submission map[string]string     `json:"submission"`
+}
+
+type webhookResponse struct {
+	Key    string `json:"key"`
+	URL    string `json:"url"`
+	Status string `json:"status"`
+}
+
+func (ask *Asker) NewWebhook(secret string) (*WebhookClient, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("Webhooks are always signed, set --webhooksecret to use them")
+	}
+	return &WebhookClient{secret: secret, hosts: ask.WebhookHosts}, nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of `timestamp.body`, which is sent
+// in the X-Ask-Signature header as `sha256=<signature>`
+func (wh *WebhookClient) Sign(timestamp string, body []byte) string {
+	return signPayload(wh.secret, timestamp, body)
+}
+
+func signPayload(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// postSigned posts the payload as JSON, signed with the secret, and decodes any
+// response body into out. The endpoint is checked against hosts again, since
+// where its name points can change after it was configured.
+func postSigned(endpoint string, secret string, hosts []string, payload interface{}, out interface{}) error {
+	if secret == "" {
+		return fmt.Errorf("Not posting to %s without --webhooksecret to sign it", endpoint)
+	}
+	if err := validateEndpoint(endpoint, hosts); err != nil {
+		return err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Ask-Timestamp", timestamp)
+	req.Header.Set("X-Ask-Signature", "sha256="+signPayload(secret, timestamp, body))
+
+	resp, err := getHTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s responded with %s: %s", endpoint, resp.Status, string(respBody))
+	}
+
+	if len(respBody) > 0 && out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("Unable to read response from %s: %v", endpoint, err)
+		}
+	}
+	return nil
+}
+
+func (wh *WebhookClient) CreateTicket(issueRequest *TicketRequest) (*Ticket, error) {
+	if err := wh.ValidateProject(issueRequest.ProjectKey); err != nil {
+		return nil, err
+	}
+
+	result := webhookResponse{}
+	err := postSigned(issueRequest.ProjectKey, wh.secret, wh.hosts, webhookPayload{
+		Ticket:     issueRequest,
+		Command:    issueRequest.Command,
+		Submission: issueRequest.Submission,
+	}, &result)
+	if err != nil {
+		log.Printf("Unable to post ask to webhook: %s\n", err)
+		return nil, err
+	}
+	if parsed, err := url.Parse(result.URL); result.URL != "" && (err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https")) {
+		log.Printf("Ignoring the URL %s responded with, it isn't http(s): %s\n", issueRequest.ProjectKey, result.URL)
+		result.URL = ""
+	}
+	return &Ticket{Key: result.Key, URL: result.URL, Summary: issueRequest.Summary, Status: result.Status}, nil
+}
+
+func (wh *WebhookClient) ValidateProject(project string) error {
+	return validateEndpoint(project, wh.hosts)
+}
+
+// validateEndpoint makes sure we only ever send asks over https, and only to
+// the --webhookhosts allowed. Without any, anything on the internal network
+// (loopback, private and link-local addresses) is off limits, since any
+// channel member can set where asks go.
+func validateEndpoint(endpoint string, hosts []string) error {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "https" || parsed.Hostname() == "" {
+		return fmt.Errorf("Webhooks must be https URLs, not `%s`", endpoint)
+	}
+
+	if len(hosts) > 0 {
+		for _, host := range hosts {
+			if strings.ToLower(host) == strings.ToLower(parsed.Hostname()) {
+				return nil
+			}
+		}
+		return fmt.Errorf("`%s` is not one of the hosts webhooks can be sent to, check --webhookhosts", parsed.Hostname())
+	}
+
+	ips, err := net.LookupIP(parsed.Hostname())
+	if err != nil {
+		return fmt.Errorf("Unable to look up `%s`: %v", parsed.Hostname(), err)
+	}
+	for _, ip := range ips {
+		if isInternalIP(ip) {
+			return fmt.Errorf("`%s` is an internal address, add it to --webhookhosts to send webhooks there", parsed.Hostname())
+		}
+	}
+	return nil
+}
+
+// privateNetworks are the IPv4 and IPv6 ranges set aside for private networks
+var privateNetworks = []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7"}
+
+func isInternalIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, network := range privateNetworks {
+		if _, block, err := net.ParseCIDR(network); err == nil && block.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (wh *WebhookClient) GetTicket(project string, key string) (*Ticket, error) {
+	return nil, fmt.Errorf("Webhooks can not look up tickets")
+}
+
+// GetTicketURL has nothing to go on, the URL only comes back from CreateTicket
+func (wh *WebhookClient) GetTicketURL(project string, key string) string {
+	return ""
+}
+
+func (wh *WebhookClient) GetComponents(project string) ([]string, error) {
+	return []string{}, nil
+}
@@ -0,0 +1,98 @@
+package asker
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestValidateEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		hosts    []string
+		valid    bool
+	}{
+		{"https://93.184.216.34/asks", nil, true},
+		{"http://93.184.216.34/asks", nil, false},
+		{"https:///asks", nil, false},
+		{"https://127.0.0.1/asks", nil, false},
+		{"https://[::1]:8443/asks", nil, false},
+		{"https://10.1.2.3/asks", nil, false},
+		{"https://172.20.0.1/asks", nil, false},
+		{"https://192.168.1.1/asks", nil, false},
+		{"https://169.254.169.254/latest/meta-data", nil, false},
+		{"https://0.0.0.0/asks", nil, false},
+		{"https://tools.internal/asks", []string{"tools.internal"}, true},
+		{"https://Tools.Internal:8443/asks", []string{"tools.internal"}, true},
+		{"https://10.1.2.3/asks", []string{"10.1.2.3"}, true},
+		{"https://93.184.216.34/asks", []string{"tools.internal"}, false},
+		{"http://tools.internal/asks", []string{"tools.internal"}, false},
+	}
+	for _, test := range tests {
+		err := validateEndpoint(test.endpoint, test.hosts)
+		if test.valid && err != nil {
+			t.Errorf("validateEndpoint(%q, %v) returned %v", test.endpoint, test.hosts, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("validateEndpoint(%q, %v) was accepted, want an error", test.endpoint, test.hosts)
+		}
+	}
+}
+
+func TestTicketLink(t *testing.T) {
+	tests := []struct {
+		ticket Ticket
+		want   string
+	}{
+		{Ticket{Key: "PROJ-1", URL: "https://jira.example.com/browse/PROJ-1"}, "<https://jira.example.com/browse/PROJ-1|PROJ-1>"},
+		{Ticket{Key: "PROJ-1"}, "PROJ-1"},
+		{Ticket{}, "received"},
+		{Ticket{Key: "<!channel>"}, "&lt;!channel&gt;"},
+		{Ticket{Key: "TOOL-1", URL: "https://x.example.com/?a=1&b=<2>|3"}, "<https://x.example.com/?a=1&amp;b=&lt;2&gt;%7C3|TOOL-1>"},
+	}
+	for _, test := range tests {
+		if got := ticketLink(&test.ticket); got != test.want {
+			t.Errorf("ticketLink(%+v) = %q, want %q", test.ticket, got, test.want)
+		}
+	}
+}
+
+func TestWebhookCreateTicket(t *testing.T) {
+	var signature string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get("X-Ask-Signature") != "sha256="+signPayload("secret", r.Header.Get("X-Ask-Timestamp"), body) {
+			t.Errorf("Webhook signature %q doesn't match the body", r.Header.Get("X-Ask-Signature"))
+		}
+		signature = r.Header.Get("X-Ask-Signature")
+		json.NewEncoder(w).Encode(map[string]string{"key": "TOOL-1", "url": "javascript:alert(1)"})
+	}))
+	defer server.Close()
+	customHTTPClient = server.Client()
+	defer func() { customHTTPClient = nil }()
+
+	endpoint, _ := url.Parse(server.URL)
+	hook, err := (&Asker{WebhookHosts: []string{endpoint.Hostname()}}).NewWebhook("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ticket, err := hook.CreateTicket(&TicketRequest{ProjectKey: server.URL + "/asks", Summary: "Help"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signature == "" {
+		t.Errorf("The ask wasn't posted to the webhook")
+	}
+	if ticket.Key != "TOOL-1" || ticket.URL != "" {
+		t.Errorf("CreateTicket returned %+v, want TOOL-1 without the javascript: URL", ticket)
+	}
+
+	// Only the allowed hosts
+	hook, _ = (&Asker{WebhookHosts: []string{"tools.example.com"}}).NewWebhook("secret")
+	if _, err := hook.CreateTicket(&TicketRequest{ProjectKey: server.URL + "/asks", Summary: "Help"}); err == nil {
+		t.Errorf("Posted to a host that isn't allowed")
+	}
+}
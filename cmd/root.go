@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/jshirley/slack-ask/asker"
 
@@ -32,12 +33,26 @@ var (
 	clientId     string
 	secret       string
 	token        string
+	signing      string
 	mongodb      string
 	bind         string
+	public       string
 	jiraEndpoint string
 	jiraUsername string
 	jiraPassword string
 	jiraPublic   string
+	jiraReporter string
+	jiraAuth     string
+	jiraToken    string
+	jiraConsumer string
+	jiraKey      string
+	jiraHook     string
+	github       string
+	githubToken  string
+	gitlab       string
+	gitlabToken  string
+	webhookKey   string
+	webhookHosts string
 )
 
 // RootCmd represents the base command when called without any subcommands
@@ -55,8 +70,8 @@ var RootCmd = &cobra.Command{
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	Run: func(cmd *cobra.Command, args []string) {
-		if viper.GetString("oauth") == "" {
-			fmt.Printf("Multi-account support is not setup yet, use oauth first and install manually into the workspace")
+		if viper.GetString("oauth") == "" && (viper.GetString("client") == "" || viper.GetString("secret") == "") {
+			log.Fatal("Use --oauth to run in a single workspace, or --client and --secret to install into several")
 			return
 		}
 
@@ -66,6 +81,15 @@ var RootCmd = &cobra.Command{
 			return
 		}
 
+		client.SigningSecret = viper.GetString("signingsecret")
+		client.ClientID = viper.GetString("client")
+		client.ClientSecret = viper.GetString("secret")
+		client.PublicURL = viper.GetString("public")
+		if client.SigningSecret == "" && client.Token == "" {
+			log.Fatal("Slack requests can't be verified, set the signing secret (or the legacy verification token)")
+			return
+		}
+
 		var dialog asker.Dialog
 		unmarshalError := viper.Unmarshal(&dialog)
 		if unmarshalError == nil && len(dialog.Elements) > 0 {
@@ -76,13 +100,71 @@ var RootCmd = &cobra.Command{
 			}
 		}
 
+		var fields []asker.FieldMapping
+		if err := viper.UnmarshalKey("fields", &fields); err == nil && len(fields) > 0 {
+			if err := client.SetFieldMappings(fields); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		}
+
 		if viper.GetString("jira") != "" {
-			jiraClient, err := client.NewJira(viper.GetString("jira"), viper.GetString("jirauser"), viper.GetString("jirapass"), viper.GetString("publicJira"))
+			auth := asker.JiraAuth{
+				Mode:           viper.GetString("jiraauth"),
+				Username:       viper.GetString("jirauser"),
+				Password:       viper.GetString("jirapass"),
+				Token:          viper.GetString("jiratoken"),
+				AccessToken:    viper.GetString("jiratoken"),
+				ConsumerKey:    viper.GetString("jiraconsumerkey"),
+				PrivateKeyFile: viper.GetString("jiraprivatekey"),
+			}
+			jiraClient, err := client.NewJira(viper.GetString("jira"), auth, viper.GetString("publicJira"))
+			if err != nil {
+				log.Fatal(err)
+				return
+			}
+			jiraClient.SetServiceReporter(viper.GetString("jirareporter"))
+			client.JiraWebhookSecret = viper.GetString("jirawebhooksecret")
+			client.RegisterBackend("jira", jiraClient)
+		}
+
+		if viper.GetString("githubtoken") != "" {
+			githubClient, err := client.NewGithub(viper.GetString("github"), viper.GetString("githubtoken"))
+			if err != nil {
+				log.Fatal(err)
+				return
+			}
+			client.RegisterBackend("github", githubClient)
+		}
+
+		if viper.GetString("gitlab") != "" {
+			gitlabClient, err := client.NewGitlab(viper.GetString("gitlab"), viper.GetString("gitlabtoken"))
+			if err != nil {
+				log.Fatal(err)
+				return
+			}
+			client.RegisterBackend("gitlab", gitlabClient)
+		}
+
+		if viper.GetString("public") != "" {
+			// Questions are only readable through their pages, so they need a public URL
+			client.RegisterBackend("local", client.NewLocalTracker(viper.GetString("public")))
+		}
+		client.WebhookSecret = viper.GetString("webhooksecret")
+		if hosts := viper.GetString("webhookhosts"); hosts != "" {
+			client.WebhookHosts = strings.Split(hosts, ",")
+		}
+		if client.WebhookSecret != "" {
+			webhookClient, err := client.NewWebhook(client.WebhookSecret)
 			if err != nil {
 				log.Fatal(err)
 				return
 			}
-			client.Jira = jiraClient
+			client.RegisterBackend("webhook", webhookClient)
+		}
+		if err := client.PrepareStorage(); err != nil {
+			log.Fatal(err)
+			return
 		}
 		go client.CleanQueue()
 		client.Listen(viper.GetString("bind"))
@@ -107,27 +189,61 @@ func init() {
 	RootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.slack-ask.yaml)")
 	RootCmd.PersistentFlags().StringVar(&oauth, "oauth", "", "OAuth token for single install apps")
 	RootCmd.PersistentFlags().StringVar(&clientId, "client", "", "Slack Client ID")
-	RootCmd.PersistentFlags().StringVar(&secret, "secret", "", "Secret")
-	RootCmd.PersistentFlags().StringVar(&token, "token", "", "Slack verification token")
+	RootCmd.PersistentFlags().StringVar(&secret, "secret", "", "Slack Client Secret")
+	RootCmd.PersistentFlags().StringVar(&token, "token", "", "Slack verification token (deprecated, use the signing secret)")
+	RootCmd.PersistentFlags().StringVar(&signing, "signingsecret", "", "Slack signing secret, to verify requests from Slack")
 	RootCmd.PersistentFlags().StringVar(&mongodb, "mongodb", "localhost:27017", "Connection string for MongoDB (default is localhost:27017)")
 	RootCmd.PersistentFlags().StringVar(&bind, "bind", ":3000", "Bind address to listen on (default is 0.0.0.0:3000)")
+	RootCmd.PersistentFlags().StringVar(&public, "public", "", "The public URL slack-ask is reachable at, used to link to local questions and for the OAuth redirect")
 
 	RootCmd.PersistentFlags().StringVar(&jiraEndpoint, "jira", "", "The JIRA endpoint to use")
 	RootCmd.PersistentFlags().StringVar(&jiraUsername, "jirauser", "", "The JIRA username")
-	RootCmd.PersistentFlags().StringVar(&jiraPassword, "jirapass", "", "The JIRA password")
+	RootCmd.PersistentFlags().StringVar(&jiraPassword, "jirapass", "", "The JIRA password (or API token for JIRA Cloud)")
+	RootCmd.PersistentFlags().StringVar(&jiraAuth, "jiraauth", "basic", "How to authenticate to JIRA: basic, cloud, token or oauth")
+	RootCmd.PersistentFlags().StringVar(&jiraToken, "jiratoken", "", "The JIRA personal access token, or OAuth access token")
+	RootCmd.PersistentFlags().StringVar(&jiraConsumer, "jiraconsumerkey", "", "The JIRA OAuth consumer key")
+	RootCmd.PersistentFlags().StringVar(&jiraKey, "jiraprivatekey", "", "Path to the PEM encoded RSA private key for JIRA OAuth")
+	RootCmd.PersistentFlags().StringVar(&jiraHook, "jirawebhooksecret", "", "The secret JIRA webhooks send as the `secret` query parameter")
 	RootCmd.PersistentFlags().StringVar(&jiraPublic, "publicJira", "", "The JIRA public endpoint (to link tickets at), you may not need this.")
+	RootCmd.PersistentFlags().StringVar(&jiraReporter, "jirareporter", "", "The JIRA user to report issues as when the Slack user has no JIRA account")
+
+	RootCmd.PersistentFlags().StringVar(&github, "github", "", "The GitHub API endpoint to use (default is https://api.github.com)")
+	RootCmd.PersistentFlags().StringVar(&githubToken, "githubtoken", "", "The GitHub access token to open issues with")
+
+	RootCmd.PersistentFlags().StringVar(&gitlab, "gitlab", "", "The GitLab endpoint to use")
+	RootCmd.PersistentFlags().StringVar(&gitlabToken, "gitlabtoken", "", "The GitLab access token to open issues with")
+
+	RootCmd.PersistentFlags().StringVar(&webhookKey, "webhooksecret", "", "The secret used to sign outgoing webhooks and assignment requests")
+	RootCmd.PersistentFlags().StringVar(&webhookHosts, "webhookhosts", "", "Comma separated hosts webhooks and assignment requests may be sent to (default is any public host)")
 
 	viper.BindPFlag("oauth", RootCmd.PersistentFlags().Lookup("oauth"))
 	viper.BindPFlag("client", RootCmd.PersistentFlags().Lookup("client"))
 	viper.BindPFlag("secret", RootCmd.PersistentFlags().Lookup("secret"))
 	viper.BindPFlag("token", RootCmd.PersistentFlags().Lookup("token"))
+	viper.BindPFlag("signingsecret", RootCmd.PersistentFlags().Lookup("signingsecret"))
 	viper.BindPFlag("mongodb", RootCmd.PersistentFlags().Lookup("mongodb"))
 	viper.BindPFlag("bind", RootCmd.PersistentFlags().Lookup("bind"))
+	viper.BindPFlag("public", RootCmd.PersistentFlags().Lookup("public"))
 
 	viper.BindPFlag("jira", RootCmd.PersistentFlags().Lookup("jira"))
 	viper.BindPFlag("jirauser", RootCmd.PersistentFlags().Lookup("jirauser"))
 	viper.BindPFlag("jirapass", RootCmd.PersistentFlags().Lookup("jirapass"))
+	viper.BindPFlag("jiraauth", RootCmd.PersistentFlags().Lookup("jiraauth"))
+	viper.BindPFlag("jiratoken", RootCmd.PersistentFlags().Lookup("jiratoken"))
+	viper.BindPFlag("jiraconsumerkey", RootCmd.PersistentFlags().Lookup("jiraconsumerkey"))
+	viper.BindPFlag("jiraprivatekey", RootCmd.PersistentFlags().Lookup("jiraprivatekey"))
+	viper.BindPFlag("jirawebhooksecret", RootCmd.PersistentFlags().Lookup("jirawebhooksecret"))
 	viper.BindPFlag("publicJira", RootCmd.PersistentFlags().Lookup("publicJira"))
+	viper.BindPFlag("jirareporter", RootCmd.PersistentFlags().Lookup("jirareporter"))
+
+	viper.BindPFlag("github", RootCmd.PersistentFlags().Lookup("github"))
+	viper.BindPFlag("githubtoken", RootCmd.PersistentFlags().Lookup("githubtoken"))
+
+	viper.BindPFlag("gitlab", RootCmd.PersistentFlags().Lookup("gitlab"))
+	viper.BindPFlag("gitlabtoken", RootCmd.PersistentFlags().Lookup("gitlabtoken"))
+
+	viper.BindPFlag("webhooksecret", RootCmd.PersistentFlags().Lookup("webhooksecret"))
+	viper.BindPFlag("webhookhosts", RootCmd.PersistentFlags().Lookup("webhookhosts"))
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -25,6 +25,8 @@ type Collection interface {
 
 type MongoDatabase struct {
 	*mgo.Database
+	// workspace is who the data belongs to, see ForWorkspace
+	workspace string
 }
 
 func (d MongoDatabase) C(name string) Collection {
@@ -33,13 +35,32 @@ func (d MongoDatabase) C(name string) Collection {
 
 type DataLayer interface {
 	C(name string) Collection
-	SetChannelProject(channelID string, project string) error
+	ForWorkspace(teamID string, enterpriseID string) DataLayer
+	EnsureIndexes() error
+	MigrateWorkspace(teamID string, enterpriseID string) error
+	CountUnscoped() (int, error)
+	SetChannelProject(channelID string, channelName string, targets []LinkTarget) error
 	SetChannelConfig(config *ChannelConfig) error
 	GetChannelConfig(channelID string) (*ChannelConfig, error)
+	GetChannelConfigs() ([]ChannelConfig, error)
 	StoreCallback(callbackID string, command *SlashCommand) error
 	RemoveCallback(callbackID string) error
 	RemoveStaleCallbacks(timeout int64) error
 	GetCallback(callbackID string) (*SlashCommand, error)
+	CreateQuestion(question *Question) error
+	GetQuestion(project string, key string) (*Question, error)
+	GetRotation(channelID string) (*Rotation, error)
+	SetRotation(rotation *Rotation) error
+	AdvanceRotation(channelID string) (*Rotation, error)
+	GetUserMapping(backend string, slackID string) (*UserMapping, error)
+	SetUserMapping(mapping *UserMapping) error
+	StoreAskMessage(message *AskMessage) error
+	GetAskMessage(backend string, key string) (*AskMessage, error)
+	GetThreadAskMessages(channelID string, timestamp string) ([]AskMessage, error)
+	GetTeam(teamID string) (*Team, error)
+	SetTeam(team *Team) error
+	RemoveTeam(teamID string) error
+	MarkEventHandled(eventID string) (bool, error)
 }
 
 // Session is an interface to access to the Session struct.
@@ -9,35 +9,37 @@ import (
 const CALLBACK_COLLECTION = "internal_callbacks"
 
 type SlashCommand struct {
-	Token          string `schema:"token"`
-	TeamID         string `schema:"team_id" bson:"team_id"`
-	TeamDomain     string `schema:"team_domain" bson:"team_domain"`
-	EnterpriseID   string `schema:"enterprise_id" bson:"enterprise_id"`
-	EnterpriseName string `schema:"enterprise_name" bson:"enterprise_name"`
-	ChannelID      string `schema:"channel_id" bson:"channel_id"`
-	ChannelName    string `schema:"channel_name" bson:"channel_name"`
-	UserID         string `schema:"user_id" bson:"user_id"`
-	UserName       string `schema:"user_name" bson:"user_name"`
-	Command        string `schema:"command"`
-	Text           string `schema:"text"`
-	ResponseURL    string `schema:"response_url" bson:"response_url"`
-	TriggerID      string `schema:"trigger_id" bson:"trigger_id"`
-	Timestamp      int64  `schema:"timestamp"`
-
-	Config *ChannelConfig `bson:"-"`
+	Token          string `schema:"token" json:"-"`
+	TeamID         string `schema:"team_id" bson:"team_id" json:"team_id"`
+	TeamDomain     string `schema:"team_domain" bson:"team_domain" json:"team_domain"`
+	EnterpriseID   string `schema:"enterprise_id" bson:"enterprise_id" json:"enterprise_id"`
+	EnterpriseName string `schema:"enterprise_name" bson:"enterprise_name" json:"enterprise_name"`
+	ChannelID      string `schema:"channel_id" bson:"channel_id" json:"channel_id"`
+	ChannelName    string `schema:"channel_name" bson:"channel_name" json:"channel_name"`
+	UserID         string `schema:"user_id" bson:"user_id" json:"user_id"`
+	UserName       string `schema:"user_name" bson:"user_name" json:"user_name"`
+	Command        string `schema:"command" json:"command"`
+	Text           string `schema:"text" json:"text"`
+	ResponseURL    string `schema:"response_url" bson:"response_url" json:"-"`
+	TriggerID      string `schema:"trigger_id" bson:"trigger_id" json:"-"`
+	Timestamp      int64  `schema:"timestamp" json:"timestamp"`
+
+	Workspace string         `schema:"-" bson:"workspace" json:"-"`
+	Config    *ChannelConfig `schema:"-" bson:"-" json:"-"`
 }
 
 func (db *MongoDatabase) StoreCallback(callbackID string, command *SlashCommand) error {
 	c := db.C(CALLBACK_COLLECTION)
 
-	_, err := c.UpsertId(callbackID, command)
+	command.Workspace = db.workspace
+	_, err := c.UpsertId(db.scopedID(callbackID), command)
 
 	return err
 }
 
 func (db *MongoDatabase) RemoveCallback(callbackID string) error {
 	c := db.C(CALLBACK_COLLECTION)
-	_, err := c.RemoveAll(bson.M{"_id": callbackID})
+	_, err := c.RemoveAll(bson.M{"_id": db.scopedID(callbackID)})
 	return err
 }
 
@@ -51,7 +53,7 @@ func (db *MongoDatabase) GetCallback(callbackID string) (*SlashCommand, error) {
 	c := db.C(CALLBACK_COLLECTION)
 	result := SlashCommand{}
 
-	err := c.Find(bson.M{"_id": callbackID}).One(&result)
+	err := c.Find(bson.M{"_id": db.scopedID(callbackID)}).One(&result)
 	if err != nil {
 		return nil, err
 	}
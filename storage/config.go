@@ -1,25 +1,63 @@
 package storage
 
 import (
+	"fmt"
+
 	"gopkg.in/mgo.v2/bson"
 )
 
+// LinkTarget is a backend, and the project in it, that asks are created in
+type LinkTarget struct {
+	Backend string
+	Project string
+}
+
+// PriorityMapping is what an answer to the dialog's `blocking` select turns into on the ticket
+type PriorityMapping struct {
+	Priority string
+	Labels   []string
+}
+
 type ChannelConfig struct {
+	Workspace      string
 	ChannelID      string
 	ChannelName    string
+	Backend        string
 	Project        string
+	Targets        []LinkTarget
 	Components     []string
+	IssueType      string
+	Priorities     map[string]PriorityMapping
 	AssignEndpoint string
+	MirrorThreads  bool
 }
 
 const CONFIG_COLLECTION = "channel_configs"
 
-func (db *MongoDatabase) SetChannelProject(channelID string, project string) error {
-	channelConfig := &ChannelConfig{ChannelID: channelID, Project: project}
+// LinkTargets returns every target asks are created in, in order. Backend and
+// Project are always the first target, and are all that older configs have.
+func (config *ChannelConfig) LinkTargets() []LinkTarget {
+	if len(config.Targets) > 0 {
+		return config.Targets
+	}
+	return []LinkTarget{LinkTarget{Backend: config.Backend, Project: config.Project}}
+}
+
+// SetChannelProject links the channel, keeping the rest of its configuration when it is relinked
+func (db *MongoDatabase) SetChannelProject(channelID string, channelName string, targets []LinkTarget) error {
+	if len(targets) < 1 {
+		return fmt.Errorf("At least one project is required to link a channel")
+	}
 	c := db.C(CONFIG_COLLECTION)
 
-	// TODO: Validate that project is even legit by talking to the JIRA API
-	_, err := c.UpsertId(channelConfig.ChannelID, channelConfig)
+	_, err := c.UpsertId(db.scopedID(channelID), bson.M{"$set": bson.M{
+		"workspace":   db.workspace,
+		"channelid":   channelID,
+		"channelname": channelName,
+		"backend":     targets[0].Backend,
+		"project":     targets[0].Project,
+		"targets":     targets,
+	}})
 
 	return err
 }
@@ -28,7 +66,8 @@ func (db *MongoDatabase) SetChannelConfig(channelConfig *ChannelConfig) error {
 	c := db.C(CONFIG_COLLECTION)
 
 	// TODO: Validate that project is even legit by talking to the JIRA API
-	_, err := c.UpsertId(channelConfig.ChannelID, channelConfig)
+	channelConfig.Workspace = db.workspace
+	_, err := c.UpsertId(db.scopedID(channelConfig.ChannelID), channelConfig)
 
 	return err
 }
@@ -37,9 +76,18 @@ func (db *MongoDatabase) GetChannelConfig(channelID string) (*ChannelConfig, err
 	c := db.C(CONFIG_COLLECTION)
 
 	result := ChannelConfig{}
-	err := c.Find(bson.M{"_id": channelID}).One(&result)
+	err := c.Find(bson.M{"_id": db.scopedID(channelID)}).One(&result)
 	if err != nil {
 		return nil, err
 	}
 	return &result, nil
 }
+
+// GetChannelConfigs is every linked channel in the workspace
+func (db *MongoDatabase) GetChannelConfigs() ([]ChannelConfig, error) {
+	c := db.C(CONFIG_COLLECTION)
+
+	var results []ChannelConfig
+	err := c.Find(db.scoped(bson.M{})).All(&results)
+	return results, err
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const EVENT_COLLECTION = "slack_events"
+
+// EVENT_RETENTION is how long event IDs are kept, well past Slack's last retry
+const EVENT_RETENTION = time.Hour
+
+type handledEvent struct {
+	EventID  string `bson:"_id"`
+	Received int64  `bson:"received"`
+}
+
+// MarkEventHandled records the Events API event, returning false when it was
+// already handled so Slack's retries aren't acted on twice
+func (db *MongoDatabase) MarkEventHandled(eventID string) (bool, error) {
+	c := db.C(EVENT_COLLECTION)
+
+	now := time.Now()
+	if _, err := c.RemoveAll(bson.M{"received": bson.M{"$lt": now.Add(-EVENT_RETENTION).Unix()}}); err != nil {
+		return false, err
+	}
+
+	err := c.Insert(&handledEvent{EventID: eventID, Received: now.Unix()})
+	if mgo.IsDup(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
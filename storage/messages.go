@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"gopkg.in/mgo.v2/bson"
+)
+
+const MESSAGE_COLLECTION = "ask_messages"
+
+// AskMessage is the Slack message announcing an ask, and the ticket it became
+type AskMessage struct {
+	Workspace string `bson:"workspace"`
+	TeamID    string `bson:"team_id"`
+	Backend   string `bson:"backend"`
+	Project   string `bson:"project"`
+	Key       string `bson:"key"`
+	URL       string `bson:"url"`
+	Summary   string `bson:"summary"`
+	ChannelID string `bson:"channel_id"`
+	Timestamp string `bson:"ts"`
+	UserID    string `bson:"user_id"`
+	Created   int64  `bson:"created"`
+
+	// Who used the buttons on the message, by Slack user ID
+	ClaimedBy   string `bson:"claimed_by,omitempty"`
+	ResolvedBy  string `bson:"resolved_by,omitempty"`
+	EscalatedBy string `bson:"escalated_by,omitempty"`
+}
+
+// Ask messages are keyed by workspace and ticket, since keys like the local
+// backend's ASK-1 repeat between workspaces
+func (db *MongoDatabase) StoreAskMessage(message *AskMessage) error {
+	c := db.C(MESSAGE_COLLECTION)
+
+	if db.workspace != "" {
+		message.Workspace = db.workspace
+	}
+	_, err := c.UpsertId(message.Workspace+":"+message.Backend+":"+message.Key, message)
+
+	return err
+}
+
+// GetAskMessage finds the ask that created the ticket. JIRA webhooks don't know
+// the workspace, so the unscoped DataLayer looks in all of them.
+func (db *MongoDatabase) GetAskMessage(backend string, key string) (*AskMessage, error) {
+	c := db.C(MESSAGE_COLLECTION)
+
+	result := AskMessage{}
+	err := c.Find(db.scoped(bson.M{"backend": backend, "key": key})).One(&result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetThreadAskMessages finds the asks announced by the message at the top of a thread
+func (db *MongoDatabase) GetThreadAskMessages(channelID string, timestamp string) ([]AskMessage, error) {
+	c := db.C(MESSAGE_COLLECTION)
+
+	var results []AskMessage
+	err := c.Find(db.scoped(bson.M{"channel_id": channelID, "ts": timestamp})).All(&results)
+	return results, err
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"fmt"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const QUESTION_COLLECTION = "questions"
+const COUNTER_COLLECTION = "question_counters"
+
+// Question is an ask tracked natively, for channels that don't use an external tracker
+type Question struct {
+	Workspace   string   `bson:"workspace"`
+	Key         string   `bson:"key"`
+	Number      int      `bson:"number"`
+	ChannelID   string   `bson:"channel_id"`
+	Project     string   `bson:"project"`
+	Summary     string   `bson:"summary"`
+	Description string   `bson:"description"`
+	Components  []string `bson:"components"`
+	Status      string   `bson:"status"`
+	Reporter    string   `bson:"reporter"`
+	Assignee    string   `bson:"assignee"`
+	Created     int64    `bson:"created"`
+}
+
+type questionCounter struct {
+	Sequence int `bson:"seq"`
+}
+
+// CreateQuestion assigns the next number for the project and stores the
+// question. Numbers are per project, since questions are found by project and
+// key, skipping any taken back when they were counted per channel.
+func (db *MongoDatabase) CreateQuestion(question *Question) error {
+	change := mgo.Change{
+		Update:    bson.M{"$inc": bson.M{"seq": 1}},
+		Upsert:    true,
+		ReturnNew: true,
+	}
+	for {
+		counter := questionCounter{}
+		if _, err := db.C(COUNTER_COLLECTION).Find(bson.M{"_id": db.scopedID("project:" + question.Project)}).Apply(change, &counter); err != nil {
+			return err
+		}
+
+		key := fmt.Sprintf("%s-%d", question.Project, counter.Sequence)
+		taken, err := db.C(QUESTION_COLLECTION).Find(db.scoped(bson.M{"project": question.Project, "key": key})).Count()
+		if err != nil {
+			return err
+		}
+		if taken == 0 {
+			question.Number, question.Key = counter.Sequence, key
+			break
+		}
+	}
+
+	question.Workspace = db.workspace
+	return db.C(QUESTION_COLLECTION).Insert(question)
+}
+
+func (db *MongoDatabase) GetQuestion(project string, key string) (*Question, error) {
+	c := db.C(QUESTION_COLLECTION)
+
+	result := Question{}
+	err := c.Find(db.scoped(bson.M{"project": project, "key": key})).Sort("-created").One(&result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const ROTATION_COLLECTION = "rotations"
+
+// DEFAULT_SHIFT is how long each person is on call for until a channel sets its own shift length
+const DEFAULT_SHIFT = 7 * 24 * time.Hour
+
+// RotationMember is someone on call, SlackID is who gets mentioned and Name is
+// who tickets are assigned to in the backend
+type RotationMember struct {
+	SlackID string
+	Name    string
+}
+
+// RotationOverride puts Member on call from Start until End, regardless of the schedule
+type RotationOverride struct {
+	Member RotationMember
+	Start  int64
+	End    int64
+}
+
+type Rotation struct {
+	Workspace  string
+	ChannelID  string
+	Members    []RotationMember
+	Overrides  []RotationOverride
+	RoundRobin bool
+	// ShiftLength is in seconds, Handoff is when a shift starts (as a unix timestamp)
+	ShiftLength int64
+	Handoff     int64
+	// Next is how many asks have been handed out when RoundRobin is set
+	Next int
+}
+
+// OnCall is whoever is on call at the given time, with overrides taking
+// precedence over the schedule
+func (rotation *Rotation) OnCall(now time.Time) *RotationMember {
+	for _, override := range rotation.Overrides {
+		if override.Start <= now.Unix() && now.Unix() < override.End {
+			return &override.Member
+		}
+	}
+
+	if len(rotation.Members) == 0 {
+		return nil
+	}
+	if rotation.RoundRobin {
+		return &rotation.Members[rotation.Next%len(rotation.Members)]
+	}
+
+	shift := rotation.ShiftLength
+	if shift <= 0 {
+		shift = int64(DEFAULT_SHIFT / time.Second)
+	}
+	elapsed := now.Unix() - rotation.Handoff
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return &rotation.Members[int(elapsed/shift)%len(rotation.Members)]
+}
+
+func (db *MongoDatabase) GetRotation(channelID string) (*Rotation, error) {
+	c := db.C(ROTATION_COLLECTION)
+
+	result := Rotation{}
+	err := c.Find(bson.M{"_id": db.scopedID(channelID)}).One(&result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (db *MongoDatabase) SetRotation(rotation *Rotation) error {
+	c := db.C(ROTATION_COLLECTION)
+
+	rotation.Workspace = db.workspace
+	_, err := c.UpsertId(db.scopedID(rotation.ChannelID), rotation)
+
+	return err
+}
+
+// AdvanceRotation hands out the next ask for round robin rotations, returning
+// the rotation as it was before advancing
+func (db *MongoDatabase) AdvanceRotation(channelID string) (*Rotation, error) {
+	c := db.C(ROTATION_COLLECTION)
+
+	result := Rotation{}
+	change := mgo.Change{Update: bson.M{"$inc": bson.M{"next": 1}}}
+	if _, err := c.Find(bson.M{"_id": db.scopedID(channelID)}).Apply(change, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRotationOnCall(t *testing.T) {
+	alice := RotationMember{SlackID: "U1", Name: "alice"}
+	bob := RotationMember{SlackID: "U2", Name: "bob"}
+	carol := RotationMember{SlackID: "U3", Name: "carol"}
+	week := int64(DEFAULT_SHIFT / time.Second)
+
+	tests := []struct {
+		name     string
+		rotation Rotation
+		now      int64
+		want     *RotationMember
+	}{
+		{"nobody", Rotation{}, 100, nil},
+		{"first shift", Rotation{Members: []RotationMember{alice, bob}, ShiftLength: 3600, Handoff: 1000}, 1000, &alice},
+		{"second shift", Rotation{Members: []RotationMember{alice, bob}, ShiftLength: 3600, Handoff: 1000}, 1000 + 3600, &bob},
+		{"wraps around", Rotation{Members: []RotationMember{alice, bob}, ShiftLength: 3600, Handoff: 1000}, 1000 + 2*3600 + 5, &alice},
+		{"before the first handoff", Rotation{Members: []RotationMember{alice, bob}, ShiftLength: 3600, Handoff: 1000}, 10, &alice},
+		{"default shift", Rotation{Members: []RotationMember{alice, bob}, Handoff: 0}, week - 1, &alice},
+		{"default shift over", Rotation{Members: []RotationMember{alice, bob}, Handoff: 0}, week, &bob},
+		{"round robin", Rotation{Members: []RotationMember{alice, bob, carol}, RoundRobin: true, Next: 4}, 100, &bob},
+		{"override", Rotation{
+			Members:   []RotationMember{alice, bob},
+			Overrides: []RotationOverride{{Member: carol, Start: 50, End: 150}},
+		}, 100, &carol},
+		{"override over", Rotation{
+			Members:   []RotationMember{alice, bob},
+			Overrides: []RotationOverride{{Member: carol, Start: 50, End: 100}},
+		}, 100, &alice},
+		{"override with nobody in the rotation", Rotation{
+			Overrides: []RotationOverride{{Member: carol, Start: 50, End: 150}},
+		}, 100, &carol},
+	}
+	for _, test := range tests {
+		got := test.rotation.OnCall(time.Unix(test.now, 0))
+		if (got == nil) != (test.want == nil) || (got != nil && *got != *test.want) {
+			t.Errorf("%s: OnCall = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
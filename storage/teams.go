@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+const TEAM_COLLECTION = "teams"
+
+// Team is a workspace slack-ask was installed into, and the bot token to use there
+type Team struct {
+	TeamID       string `bson:"_id"`
+	TeamName     string `bson:"team_name"`
+	EnterpriseID string `bson:"enterprise_id,omitempty"`
+	BotToken     string `bson:"bot_token"`
+	BotUserID    string `bson:"bot_user_id"`
+	Scope        string `bson:"scope"`
+	InstalledBy  string `bson:"installed_by"`
+	Installed    int64  `bson:"installed"`
+}
+
+func (db *MongoDatabase) GetTeam(teamID string) (*Team, error) {
+	c := db.C(TEAM_COLLECTION)
+
+	result := Team{}
+	err := c.Find(bson.M{"_id": teamID}).One(&result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (db *MongoDatabase) SetTeam(team *Team) error {
+	c := db.C(TEAM_COLLECTION)
+
+	team.Installed = time.Now().Unix()
+	_, err := c.UpsertId(team.TeamID, team)
+
+	return err
+}
+
+func (db *MongoDatabase) RemoveTeam(teamID string) error {
+	c := db.C(TEAM_COLLECTION)
+	_, err := c.RemoveAll(bson.M{"_id": teamID})
+	return err
+}
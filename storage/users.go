@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+const USER_COLLECTION = "user_mappings"
+
+// USER_MAPPING_TTL is how long a resolved user is trusted before looking them up again
+const USER_MAPPING_TTL = 24 * time.Hour
+
+// UserMapping is which backend user a Slack user is. Name and AccountID are
+// empty when nobody in the backend has the Slack user's email.
+type UserMapping struct {
+	Workspace string
+	SlackID   string
+	Backend   string
+	Email     string
+	Name      string
+	AccountID string
+	Updated   int64
+}
+
+func userMappingID(backend string, slackID string) string {
+	return backend + ":" + slackID
+}
+
+func (db *MongoDatabase) GetUserMapping(backend string, slackID string) (*UserMapping, error) {
+	c := db.C(USER_COLLECTION)
+
+	result := UserMapping{}
+	err := c.Find(bson.M{"_id": db.scopedID(userMappingID(backend, slackID))}).One(&result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (db *MongoDatabase) SetUserMapping(mapping *UserMapping) error {
+	c := db.C(USER_COLLECTION)
+
+	mapping.Workspace = db.workspace
+	mapping.Updated = time.Now().Unix()
+	_, err := c.UpsertId(db.scopedID(userMappingID(mapping.Backend, mapping.SlackID)), mapping)
+
+	return err
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// WorkspaceKey is who data belongs to: the Enterprise Grid org when there is
+// one, since its channels are shared between its workspaces, otherwise the team
+func WorkspaceKey(teamID string, enterpriseID string) string {
+	if enterpriseID != "" {
+		return enterpriseID
+	}
+	return teamID
+}
+
+// ForWorkspace returns the DataLayer for a single workspace. The unscoped
+// DataLayer sees every workspace, for work that isn't on behalf of one.
+func (db *MongoDatabase) ForWorkspace(teamID string, enterpriseID string) DataLayer {
+	return &MongoDatabase{Database: db.Database, workspace: WorkspaceKey(teamID, enterpriseID)}
+}
+
+// scopedID prefixes the ID of documents keyed by channel or user with the workspace
+func (db *MongoDatabase) scopedID(id string) string {
+	if db.workspace == "" {
+		return id
+	}
+	return db.workspace + ":" + id
+}
+
+// scoped limits a query to the workspace
+func (db *MongoDatabase) scoped(query bson.M) bson.M {
+	if db.workspace != "" {
+		query["workspace"] = db.workspace
+	}
+	return query
+}
+
+func (db *MongoDatabase) EnsureIndexes() error {
+	indexes := map[string][][]string{
+		CONFIG_COLLECTION:   [][]string{[]string{"workspace"}},
+		MESSAGE_COLLECTION:  [][]string{[]string{"workspace", "channel_id", "ts"}, []string{"backend", "key"}},
+		QUESTION_COLLECTION: [][]string{[]string{"workspace", "project", "key"}},
+		EVENT_COLLECTION:    [][]string{[]string{"received"}},
+	}
+	for collection, keys := range indexes {
+		for _, key := range keys {
+			if err := db.C(collection).EnsureIndex(mgo.Index{Key: key}); err != nil {
+				return fmt.Errorf("Unable to index %s: %v", collection, err)
+			}
+		}
+	}
+	return nil
+}
+
+// workspaceCollections are the collections kept per workspace. Those keyed by
+// channel, user or ticket get their IDs prefixed like scopedID.
+var workspaceCollections = map[string]bool{
+	CONFIG_COLLECTION:   true,
+	CALLBACK_COLLECTION: true,
+	COUNTER_COLLECTION:  true,
+	ROTATION_COLLECTION: true,
+	USER_COLLECTION:     true,
+	MESSAGE_COLLECTION:  true,
+	QUESTION_COLLECTION: false,
+}
+
+// CountUnscoped is how much was stored before data was kept per workspace and
+// still hasn't been moved into one
+func (db *MongoDatabase) CountUnscoped() (int, error) {
+	total := 0
+	for collection := range workspaceCollections {
+		count, err := db.C(collection).Find(bson.M{"workspace": bson.M{"$exists": false}}).Count()
+		if err != nil {
+			return total, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// MigrateWorkspace moves everything stored before data was kept per workspace
+// into the given workspace
+func (db *MongoDatabase) MigrateWorkspace(teamID string, enterpriseID string) error {
+	workspace := WorkspaceKey(teamID, enterpriseID)
+
+	for collection, rekey := range workspaceCollections {
+		migrated, err := db.migrateCollection(collection, workspace, rekey)
+		if err != nil {
+			return fmt.Errorf("Unable to migrate %s: %v", collection, err)
+		}
+		if migrated > 0 {
+			log.Printf("Moved %d documents in %s into workspace %s\n", migrated, collection, workspace)
+		}
+	}
+	return nil
+}
+
+func (db *MongoDatabase) migrateCollection(collection string, workspace string, rekey bool) (int, error) {
+	c := db.C(collection)
+
+	migrated := 0
+	iter := c.Find(bson.M{"workspace": bson.M{"$exists": false}}).Iter()
+	doc := bson.M{}
+	for iter.Next(&doc) {
+		id := doc["_id"]
+		doc["workspace"] = workspace
+		if rekey {
+			doc["_id"] = fmt.Sprintf("%s:%v", workspace, id)
+			if err := c.Insert(doc); err != nil && !mgo.IsDup(err) {
+				iter.Close()
+				return migrated, err
+			}
+			if err := c.Remove(bson.M{"_id": id}); err != nil {
+				iter.Close()
+				return migrated, err
+			}
+		} else if err := c.Update(bson.M{"_id": id}, bson.M{"$set": bson.M{"workspace": workspace}}); err != nil {
+			iter.Close()
+			return migrated, err
+		}
+		migrated++
+		doc = bson.M{}
+	}
+	return migrated, iter.Close()
+}